@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config — настройки сервера. Источники по возрастанию приоритета:
+// значения по умолчанию, YAML- или TOML-файл (--config или TWISH_CONFIG), переменные окружения TWISH_*, флаги.
+type Config struct {
+	// Listen — адрес HTTP-сервера
+	Listen string `yaml:"listen"`
+	// ShutdownTimeout — сколько ждать завершения запросов, фоновых задач и отправки уведомлений при остановке
+	ShutdownTimeout Duration `yaml:"shutdownTimeout"`
+	// AllowedOrigins — источники, которым разрешен CORS; "*" — любые
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+	// PublicURL — внешний адрес сервера без /api/v1 для ссылок (лента календаря); пусто — по адресу запроса
+	PublicURL string `yaml:"publicUrl"`
+	// AppURL — адрес веб-приложения для ссылок из уведомлений
+	AppURL string `yaml:"appUrl"`
+
+	Storage   StorageConfig   `yaml:"storage"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Security  SecurityConfig  `yaml:"security"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Telegram  TelegramConfig  `yaml:"telegram"`
+
+	// RatesFile — JSON-файл курсов валют; пусто — встроенные курсы
+	RatesFile string `yaml:"ratesFile"`
+	// LogLevel — минимальный уровень логов: debug, info, warn, error
+	LogLevel string `yaml:"logLevel"`
+}
+
+// StorageConfig — где хранятся данные
+type StorageConfig struct {
+	// Backend — тип хранилища; пока поддерживается только memory
+	Backend string `yaml:"backend"`
+	// Path — путь к данным для файловых хранилищ; у memory должен быть пустым
+	Path string `yaml:"path"`
+}
+
+// SchedulerConfig — интервалы фоновых задач
+type SchedulerConfig struct {
+	// PriceWatchInterval — как часто проверять цены по ссылкам; 0 — не проверять
+	PriceWatchInterval Duration `yaml:"priceWatchInterval"`
+}
+
+// SecurityConfig — ключи и режим сессий
+type SecurityConfig struct {
+	// SecretKey — ключ шифрования токенов и паролей в настройках, 32 байта в base64
+	SecretKey string `yaml:"secretKey"`
+	// SessionKey — ключ подписи сессий, 32 байта в base64
+	SessionKey string `yaml:"sessionKey"`
+	// RequireSession — пускать к данным пользователя только с его сессией
+	RequireSession bool `yaml:"requireSession"`
+}
+
+// SMTPConfig — почтовый сервер по умолчанию; пользователь может указать свои ящик и пароль
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// TelegramConfig — бот по умолчанию; пользователь может указать свой токен
+type TelegramConfig struct {
+	BotToken string `yaml:"botToken"`
+}
+
+// Duration — интервал, который в YAML и переменных окружения пишется как "1h30m"
+type Duration time.Duration
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// MarshalYAML пишет интервал строкой
+func (d Duration) MarshalYAML() (any, error) { return d.String(), nil }
+
+// UnmarshalYAML читает интервал из строки вида "1h30m"
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultConfig возвращает настройки по умолчанию: те, с которыми сервер работал до появления конфигурации
+func DefaultConfig() Config {
+	return Config{
+		Listen:          ":8080",
+		ShutdownTimeout: Duration(15 * time.Second),
+		AllowedOrigins:  []string{"*"},
+		AppURL:          "http://localhost:3000",
+		Storage:         StorageConfig{Backend: "memory"},
+		Scheduler:       SchedulerConfig{PriceWatchInterval: Duration(time.Hour)},
+		Security:        SecurityConfig{RequireSession: true},
+		SMTP:            SMTPConfig{Port: 587},
+		LogLevel:        "info",
+	}
+}
+
+// configOption — параметр, который задается флагом и переменной окружения
+type configOption struct {
+	flag, env, usage string
+	set              func(c *Config, v string) error
+}
+
+func setString(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = strings.TrimSpace(v)
+		return nil
+	}
+}
+
+// configOptions — все параметры, доступные из флагов и окружения
+var configOptions = []configOption{
+	{"listen", "TWISH_LISTEN", "адрес HTTP-сервера", setString(func(c *Config) *string { return &c.Listen })},
+	{"shutdown-timeout", "TWISH_SHUTDOWN_TIMEOUT", "срок корректной остановки, например 15s", func(c *Config, v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.ShutdownTimeout = Duration(d)
+		return nil
+	}},
+	{"allowed-origins", "TWISH_ALLOWED_ORIGINS", "источники CORS через запятую; * — любые", func(c *Config, v string) error {
+		c.AllowedOrigins = nil
+		for _, o := range strings.Split(v, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, o)
+			}
+		}
+		return nil
+	}},
+	{"public-url", "TWISH_PUBLIC_URL", "внешний адрес API для ссылок", setString(func(c *Config) *string { return &c.PublicURL })},
+	{"app-url", "TWISH_APP_URL", "адрес веб-приложения для ссылок из уведомлений", setString(func(c *Config) *string { return &c.AppURL })},
+	{"storage-backend", "TWISH_STORAGE_BACKEND", "тип хранилища: memory", setString(func(c *Config) *string { return &c.Storage.Backend })},
+	{"storage-path", "TWISH_STORAGE_PATH", "путь к данным файлового хранилища; у memory не задается", setString(func(c *Config) *string { return &c.Storage.Path })},
+	{"price-watch-interval", "TWISH_PRICE_WATCH_INTERVAL", "интервал проверки цен, например 1h; 0 — выключить", func(c *Config, v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.Scheduler.PriceWatchInterval = Duration(d)
+		return nil
+	}},
+	{"rates-file", "TWISH_RATES_FILE", "JSON-файл курсов валют", setString(func(c *Config) *string { return &c.RatesFile })},
+	{"secret-key", "TWISH_SECRET_KEY", "ключ шифрования секретов, 32 байта в base64", setString(func(c *Config) *string { return &c.Security.SecretKey })},
+	{"session-key", "TWISH_SESSION_KEY", "ключ подписи сессий, 32 байта в base64", setString(func(c *Config) *string { return &c.Security.SessionKey })},
+	{"require-session", "TWISH_REQUIRE_SESSION", "требовать сессию для доступа к данным пользователя", func(c *Config, v string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.Security.RequireSession = b
+		return nil
+	}},
+	{"smtp-host", "TWISH_SMTP_HOST", "SMTP-сервер по умолчанию", setString(func(c *Config) *string { return &c.SMTP.Host })},
+	{"smtp-port", "TWISH_SMTP_PORT", "порт SMTP-сервера", func(c *Config, v string) error {
+		p, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		c.SMTP.Port = p
+		return nil
+	}},
+	{"smtp-username", "TWISH_SMTP_USERNAME", "пользователь SMTP", setString(func(c *Config) *string { return &c.SMTP.Username })},
+	{"smtp-password", "TWISH_SMTP_PASSWORD", "пароль SMTP", setString(func(c *Config) *string { return &c.SMTP.Password })},
+	{"smtp-from", "TWISH_SMTP_FROM", "адрес отправителя писем", setString(func(c *Config) *string { return &c.SMTP.From })},
+	{"log-level", "TWISH_LOG_LEVEL", "уровень логов: debug, info, warn, error", setString(func(c *Config) *string { return &c.LogLevel })},
+	{"telegram-token", "TWISH_TELEGRAM_TOKEN", "токен Telegram-бота по умолчанию", setString(func(c *Config) *string { return &c.Telegram.BotToken })},
+}
+
+// flagValue — значение флага, примененное после файла и окружения
+type flagValue struct {
+	opt   configOption
+	value string
+}
+
+// LoadConfig собирает настройки из аргументов командной строки, окружения и файла.
+// printConfig сообщает, что запрошен режим --print-config.
+func LoadConfig(args []string, getenv func(string) string) (cfg Config, printConfig bool, err error) {
+	fs := flag.NewFlagSet("twish", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("TWISH_CONFIG"), "YAML-файл настроек или TOML-файл с расширением .toml (TWISH_CONFIG)")
+	fs.BoolVar(&printConfig, "print-config", false, "вывести итоговые настройки со скрытыми секретами и выйти")
+
+	var flags []flagValue
+	for _, opt := range configOptions {
+		fs.Func(opt.flag, opt.usage+" ("+opt.env+")", func(v string) error {
+			flags = append(flags, flagValue{opt: opt, value: v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	cfg = DefaultConfig()
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath, &cfg); err != nil {
+			return Config{}, false, err
+		}
+	}
+	var errs []error
+	for _, opt := range configOptions {
+		if v, ok := lookupEnv(getenv, opt.env); ok {
+			if err := opt.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", opt.env, err))
+			}
+		}
+	}
+	for _, f := range flags {
+		if err := f.opt.set(&cfg, f.value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", f.opt.flag, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, false, err
+	}
+	return cfg, printConfig, cfg.Validate()
+}
+
+func lookupEnv(getenv func(string) string, key string) (string, bool) {
+	v := getenv(key)
+	return v, v != ""
+}
+
+// loadConfigFile читает файл поверх текущих настроек; неизвестные ключи — ошибка.
+// Файл с расширением .toml разбирается parseTOML и проверяется теми же правилами, что YAML:
+// ключи и типы значений совпадают.
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		doc, err := parseTOML(data)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validateHTTPURL проверяет, что raw — абсолютная http(s)-ссылка
+func validateHTTPURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL, got %q", name, raw)
+	}
+	return nil
+}
+
+// Validate проверяет настройки при запуске и возвращает все найденные ошибки
+func (c Config) Validate() error {
+	var errs []error
+	if _, port, err := net.SplitHostPort(c.Listen); err != nil || port == "" {
+		errs = append(errs, fmt.Errorf("listen must be host:port, got %q", c.Listen))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdownTimeout must be positive, got %s", c.ShutdownTimeout))
+	}
+	if len(c.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("allowedOrigins must not be empty, use \"*\" to allow any origin"))
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		if err := validateHTTPURL("allowedOrigins", o); err != nil {
+			errs = append(errs, err)
+		} else if u, _ := url.Parse(o); u.Path != "" {
+			errs = append(errs, fmt.Errorf("allowedOrigins must be scheme://host[:port] without a path, got %q", o))
+		}
+	}
+	if c.PublicURL != "" {
+		if err := validateHTTPURL("publicUrl", c.PublicURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validateHTTPURL("appUrl", c.AppURL); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Storage.Backend != "memory" {
+		errs = append(errs, fmt.Errorf("storage backend %q is not supported, available: memory", c.Storage.Backend))
+	} else if c.Storage.Path != "" {
+		errs = append(errs, fmt.Errorf("storage.path is not used by the memory backend, got %q", c.Storage.Path))
+	}
+	if d := time.Duration(c.Scheduler.PriceWatchInterval); d < 0 || (d > 0 && d < time.Minute) {
+		errs = append(errs, fmt.Errorf("scheduler.priceWatchInterval must be 0 or at least 1m, got %s", d))
+	}
+	for _, key := range []struct{ name, value string }{
+		{"security.secretKey", c.Security.SecretKey},
+		{"security.sessionKey", c.Security.SessionKey},
+	} {
+		if key.value == "" {
+			continue
+		}
+		if _, err := ParseSecretKey(key.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key.name, err))
+		}
+	}
+	if c.SMTP.Host != "" && (c.SMTP.Port < 1 || c.SMTP.Port > 65535) {
+		errs = append(errs, fmt.Errorf("smtp.port must be 1-65535, got %d", c.SMTP.Port))
+	}
+	if c.SMTP.From != "" {
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			errs = append(errs, fmt.Errorf("smtp.from: %w", err))
+		}
+	}
+	if _, err := c.SlogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("logLevel: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// SlogLevel разбирает LogLevel
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.LogLevel))
+	return level, err
+}
+
+// Masked возвращает копию настроек со скрытыми ключами, паролями и токенами
+func (c Config) Masked() Config {
+	c.Security.SecretKey = maskSecret(c.Security.SecretKey)
+	c.Security.SessionKey = maskSecret(c.Security.SessionKey)
+	c.SMTP.Password = maskSecret(c.SMTP.Password)
+	c.Telegram.BotToken = maskSecret(c.Telegram.BotToken)
+	return c
+}
+
+// Print выводит итоговые настройки в YAML со скрытыми секретами
+func (c Config) Print(w io.Writer) error {
+	out, err := yaml.Marshal(c.Masked())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "twish.yaml", "listen: \":7000\"\nsmtp:\n  host: file.example.com\n  port: 2525\nscheduler:\n  priceWatchInterval: 2h\n")
+	tomlFile := writeConfigFile(t, "twish.toml", `
+listen = ":7000" # адрес
+allowedOrigins = ["https://app.example.com", 'https://admin.example.com']
+
+[smtp]
+host = "file.example.com"
+port = 2_525
+
+[scheduler]
+priceWatchInterval = "2h"
+`)
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		listen   string
+		smtpHost string
+		smtpPort int
+		interval time.Duration
+	}{
+		{"defaults", nil, nil, ":8080", "", 587, time.Hour},
+		{"yaml file", []string{"--config", yamlFile}, nil, ":7000", "file.example.com", 2525, 2 * time.Hour},
+		{"toml file", []string{"--config", tomlFile}, nil, ":7000", "file.example.com", 2525, 2 * time.Hour},
+		{"file from env", nil, map[string]string{"TWISH_CONFIG": tomlFile}, ":7000", "file.example.com", 2525, 2 * time.Hour},
+		{"env over file", []string{"--config", yamlFile}, map[string]string{"TWISH_LISTEN": ":7100", "TWISH_SMTP_PORT": "465"}, ":7100", "file.example.com", 465, 2 * time.Hour},
+		{"flag over env", []string{"--config", yamlFile, "--listen", ":7200", "--price-watch-interval", "30m"},
+			map[string]string{"TWISH_LISTEN": ":7100", "TWISH_PRICE_WATCH_INTERVAL": "3h"}, ":7200", "file.example.com", 2525, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := LoadConfig(tt.args, func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Listen != tt.listen || cfg.SMTP.Host != tt.smtpHost || cfg.SMTP.Port != tt.smtpPort ||
+				time.Duration(cfg.Scheduler.PriceWatchInterval) != tt.interval {
+				t.Errorf("config = listen %q, smtp %s:%d, interval %s; want %q, %s:%d, %s",
+					cfg.Listen, cfg.SMTP.Host, cfg.SMTP.Port, time.Duration(cfg.Scheduler.PriceWatchInterval),
+					tt.listen, tt.smtpHost, tt.smtpPort, tt.interval)
+			}
+		})
+	}
+
+	cfg, _, err := LoadConfig([]string{"--config", tomlFile}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.AllowedOrigins) != 2 || cfg.AllowedOrigins[1] != "https://admin.example.com" {
+		t.Errorf("allowedOrigins from TOML = %v", cfg.AllowedOrigins)
+	}
+}
+
+func TestLoadConfigRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"storage path flag", []string{"--storage-path", "/var/lib/twish"}, nil, "storage.path is not used"},
+		{"storage path env", nil, map[string]string{"TWISH_STORAGE_PATH": "data"}, "storage.path is not used"},
+		{"short interval", []string{"--price-watch-interval", "30s"}, nil, "priceWatchInterval must be 0 or at least 1m"},
+		{"negative interval", nil, map[string]string{"TWISH_PRICE_WATCH_INTERVAL": "-1h"}, "priceWatchInterval must be 0 or at least 1m"},
+		{"bad interval", []string{"--price-watch-interval", "hourly"}, nil, "--price-watch-interval"},
+		{"smtp port range", []string{"--smtp-host", "smtp.example.com", "--smtp-port", "70000"}, nil, "smtp.port must be 1-65535"},
+		{"smtp port number", nil, map[string]string{"TWISH_SMTP_PORT": "smtp"}, "TWISH_SMTP_PORT"},
+		{"listen", []string{"--listen", "8080"}, nil, "listen must be host:port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadConfig(tt.args, func(key string) string { return tt.env[key] })
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name, file, body, wantErr string
+	}{
+		{"unknown yaml key", "twish.yaml", "lisen: \":7000\"\n", "lisen"},
+		{"unknown toml key", "twish.toml", "[smtp]\nhots = \"x\"\n", "hots"},
+		{"toml type", "twish.toml", "[smtp]\nport = \"587\"\n", "587"},
+		{"toml bad interval", "twish.toml", "[scheduler]\npriceWatchInterval = \"soon\"\n", "soon"},
+		{"toml inline table", "twish.toml", "smtp = { host = \"x\" }\n", "inline tables"},
+		{"toml duplicate key", "twish.toml", "listen = \":1\"\nlisten = \":2\"\n", "already defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, tt.file, tt.body)
+			_, _, err := LoadConfig([]string{"--config", path}, func(string) string { return "" })
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	doc, err := parseTOML([]byte(`
+# настройки
+name = "a \"quoted\" \u0442ext"
+raw = 'C:\path'
+count = -12
+ratio = 0.5
+on = true
+list = [1, "two", [3]] # вложенный массив
+
+[a.b]
+"key" = 'v'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != `a "quoted" тext` || doc["raw"] != `C:\path` || doc["count"] != int64(-12) || doc["ratio"] != 0.5 || doc["on"] != true {
+		t.Errorf("scalars = %v", doc)
+	}
+	if list := doc["list"].([]any); len(list) != 3 || list[1] != "two" {
+		t.Errorf("list = %v", doc["list"])
+	}
+	if v := doc["a"].(map[string]any)["b"].(map[string]any)["key"]; v != "v" {
+		t.Errorf("a.b.key = %v", v)
+	}
+
+	for _, bad := range []string{"x = \"\"\"\nmulti\"\"\"", "[[items]]", "when = 1979-05-27", "a.b = 1", "x = [1,\n2]"} {
+		if _, err := parseTOML([]byte(bad)); !errors.Is(err, errTOMLUnsupported) {
+			t.Errorf("parseTOML(%q) error = %v, want errTOMLUnsupported", bad, err)
+		}
+	}
+}
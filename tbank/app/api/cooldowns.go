@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultCoolingDays — охлаждение, если цена не попала ни в один диапазон
+const defaultCoolingDays = 7
+
+// cooldownGapTolerance — допустимый разрыв между соседними диапазонами:
+// «до 15 000» и «от 15 001» считаются смежными
+const cooldownGapTolerance = 1
+
+// maxCoolingDays — максимальный период охлаждения
+const maxCoolingDays = 365
+
+// FieldError — ошибка в конкретном поле запроса
+// @Description Ошибка валидации поля.
+type FieldError struct {
+	// Field — путь к полю
+	// example: "cooldowns[1].min"
+	Field string `json:"field"`
+	// Message — описание ошибки на языке из Accept-Language
+	// example: "overlaps with cooldowns[0]"
+	Message string `json:"message"`
+	// Rule — нарушенное правило валидации, если ошибка найдена по тегу validate
+	// example: "maxlen"
+	Rule string `json:"rule,omitempty"`
+	// Param — параметр правила
+	// example: "200"
+	Param string `json:"param,omitempty"`
+
+	// key — ключ сообщения в validationMessages для перевода
+	key string
+}
+
+// convertCooldowns переводит границы диапазонов из валюты from в to.
+// Верхние границы округляются до целых, нижние примыкают к предыдущему диапазону,
+// так что смежные диапазоны остаются смежными.
+func convertCooldowns(ranges []CooldownRange, from, to string, rates RateProvider) ([]CooldownRange, error) {
+	rate, err := rates.Rate(from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := sortedCooldowns(ranges)
+	if from == to {
+		return out, nil
+	}
+	for i := range out {
+		if i > 0 {
+			out[i].Min = out[i-1].Max + cooldownGapTolerance
+		} else {
+			out[i].Min = math.Round(out[i].Min * rate)
+		}
+		if !out[i].Unbounded {
+			out[i].Max = math.Max(math.Round(out[i].Max*rate), out[i].Min)
+		}
+	}
+	return out, nil
+}
+
+// sortedCooldowns возвращает копию диапазонов, упорядоченную по нижней границе
+func sortedCooldowns(ranges []CooldownRange) []CooldownRange {
+	out := append([]CooldownRange(nil), ranges...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Min < out[j].Min })
+	return out
+}
+
+// contains проверяет, попадает ли цена в диапазон
+func (r CooldownRange) contains(price float64) bool {
+	return price >= r.Min && (r.Unbounded || price <= r.Max)
+}
+
+// calcRecommendedCooling рассчитывает рекомендуемый период охлаждения по диапазонам цены
+// и ограничивает его правилом категории, если оно задано.
+// Цена в допустимом разрыве между диапазонами относится к верхнему из них.
+func calcRecommendedCooling(price float64, category string, settings Settings) int {
+	days := defaultCoolingDays
+	for _, r := range sortedCooldowns(settings.Cooldowns) {
+		if r.contains(price) || (price < r.Min && price >= r.Min-cooldownGapTolerance) {
+			days = r.Period
+			break
+		}
+	}
+	if c, ok := categoryCoolingFor(category, settings); ok {
+		if c.MinDays > 0 && days < c.MinDays {
+			days = c.MinDays
+		}
+		if c.MaxDays > 0 && days > c.MaxDays {
+			days = c.MaxDays
+		}
+	}
+	return days
+}
+
+// validateCooldowns проверяет диапазоны охлаждения: границы, периоды, пересечения и разрывы.
+// Верхний диапазон должен быть явно открытым («потолка нет»). Пустой список допустим —
+// тогда действует охлаждение по умолчанию.
+func validateCooldowns(ranges []CooldownRange) []FieldError {
+	var errs []FieldError
+	add := func(i int, field, format string, args ...any) {
+		errs = append(errs, FieldError{
+			Field:   fmt.Sprintf("cooldowns[%d].%s", i, field),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for i, r := range ranges {
+		if r.Min < 0 {
+			add(i, "min", "must not be negative")
+		}
+		if !r.Unbounded && r.Max < r.Min {
+			add(i, "max", "must be greater than or equal to min")
+		}
+		if r.Period < 1 || r.Period > maxCoolingDays {
+			add(i, "period", "must be between 1 and %d days", maxCoolingDays)
+		}
+	}
+	if len(errs) > 0 || len(ranges) == 0 {
+		return errs
+	}
+
+	// соседей сравниваем в порядке нижних границ, но ошибки указываем по исходным индексам
+	order := make([]int, len(ranges))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return ranges[order[a]].Min < ranges[order[b]].Min })
+
+	for k := 1; k < len(order); k++ {
+		prev, cur := order[k-1], order[k]
+		switch {
+		case ranges[prev].Unbounded:
+			add(prev, "unbounded", "only the top range can be open-ended")
+		case ranges[cur].Min <= ranges[prev].Max:
+			add(cur, "min", "overlaps with cooldowns[%d]", prev)
+		case ranges[cur].Min-ranges[prev].Max > cooldownGapTolerance:
+			add(cur, "min", "gap after cooldowns[%d]: prices %s-%s are not covered",
+				prev, formatFloat(ranges[prev].Max), formatFloat(ranges[cur].Min))
+		}
+	}
+	if top := order[len(order)-1]; !ranges[top].Unbounded {
+		add(top, "unbounded", "top range must be open-ended")
+	}
+	return errs
+}
+
+// CooldownProposal — исправленный набор диапазонов
+// @Description Предложенные диапазоны охлаждения и список исправлений.
+type CooldownProposal struct {
+	// Cooldowns — исправленные диапазоны
+	Cooldowns []CooldownRange `json:"cooldowns"`
+	// Fixes — что было исправлено
+	// example: ["cooldowns[1]: min 14000 -> 15001 to remove overlap"]
+	Fixes []string `json:"fixes"`
+	// Errors — ошибки исходных диапазонов
+	Errors []FieldError `json:"errors"`
+}
+
+// normalizeCooldowns предлагает корректный набор диапазонов: переставляет перевернутые
+// границы, упорядочивает, срезает пересечения, закрывает разрывы, объединяет соседние
+// диапазоны с одинаковым периодом и делает верхний диапазон открытым
+func normalizeCooldowns(ranges []CooldownRange) ([]CooldownRange, []string) {
+	fixes := []string{}
+	fix := func(format string, args ...any) { fixes = append(fixes, fmt.Sprintf(format, args...)) }
+
+	in := make([]CooldownRange, 0, len(ranges))
+	for i, r := range ranges {
+		if r.Min < 0 {
+			fix("cooldowns[%d]: min %s -> 0", i, formatFloat(r.Min))
+			r.Min = 0
+		}
+		if !r.Unbounded && r.Max < r.Min {
+			fix("cooldowns[%d]: min and max swapped", i)
+			r.Min, r.Max = r.Max, r.Min
+		}
+		if r.Period < 1 || r.Period > maxCoolingDays {
+			p := min(max(r.Period, 1), maxCoolingDays)
+			fix("cooldowns[%d]: period %d -> %d", i, r.Period, p)
+			r.Period = p
+		}
+		in = append(in, r)
+	}
+	in = sortedCooldowns(in)
+
+	out := make([]CooldownRange, 0, len(in))
+	for _, r := range in {
+		if len(out) == 0 {
+			out = append(out, r)
+			continue
+		}
+		prev := &out[len(out)-1]
+		if prev.Unbounded {
+			fix("range %s+ closed at %s", formatFloat(prev.Min), formatFloat(r.Min-cooldownGapTolerance))
+			prev.Unbounded = false
+			prev.Max = math.Max(prev.Min, r.Min-cooldownGapTolerance)
+		}
+		if r.Min <= prev.Max {
+			if !r.Unbounded && r.Max <= prev.Max {
+				fix("range %s-%s dropped: covered by %s-%s",
+					formatFloat(r.Min), formatFloat(r.Max), formatFloat(prev.Min), formatFloat(prev.Max))
+				continue
+			}
+			fix("range starting at %s moved to %s to remove overlap", formatFloat(r.Min), formatFloat(prev.Max+cooldownGapTolerance))
+			r.Min = prev.Max + cooldownGapTolerance
+		} else if r.Min-prev.Max > cooldownGapTolerance {
+			fix("range %s-%s extended to %s to close gap",
+				formatFloat(prev.Min), formatFloat(prev.Max), formatFloat(r.Min-cooldownGapTolerance))
+			prev.Max = r.Min - cooldownGapTolerance
+		}
+		if r.Period == prev.Period {
+			prev.Max, prev.Unbounded = r.Max, r.Unbounded
+			continue
+		}
+		out = append(out, r)
+	}
+
+	if n := len(out); n > 0 && !out[n-1].Unbounded {
+		fix("top range %s-%s made open-ended", formatFloat(out[n-1].Min), formatFloat(out[n-1].Max))
+		out[n-1].Unbounded = true
+		out[n-1].Max = 0
+	}
+	return out, fixes
+}
+
+// NormalizeCooldownsHandler создает обработчик, предлагающий исправленные диапазоны
+// @Summary Исправить диапазоны охлаждения
+// @Description Проверяет диапазоны из тела запроса (или сохраненные, если тело пустое) и предлагает исправленный набор. Настройки не меняются.
+// @Tags settings
+// @Param userId path string true "ID пользователя"
+// @Param cooldowns body []CooldownRange false "Диапазоны"
+// @Accept json
+// @Produce json
+// @Success 200 {object} CooldownProposal
+// @Failure 400 {object} Problem "invalid_json"
+// @Security SessionToken
+// @Router /settings/{userId}/cooldowns/normalize [post]
+func NormalizeCooldownsHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+
+		var ranges []CooldownRange
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&ranges)
+		switch {
+		case errors.Is(err, io.EOF):
+			ranges = storage.GetSettings(userId).Cooldowns
+		case err != nil:
+			writeDecodeError(w, r, err)
+			return
+		}
+
+		res := CooldownProposal{Errors: validateCooldowns(ranges)}
+		res.Cooldowns, res.Fixes = normalizeCooldowns(ranges)
+		if res.Errors == nil {
+			res.Errors = []FieldError{}
+		}
+
+		logger("handler").InfoContext(r.Context(), "cooldowns normalized", "user_id", userId, "fixes", len(res.Fixes))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
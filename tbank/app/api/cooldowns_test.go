@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCooldowns(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []CooldownRange
+		fields []string
+	}{
+		{"empty", nil, nil},
+		{"valid", []CooldownRange{{Min: 0, Max: 15000, Period: 1}, {Min: 15001, Period: 7, Unbounded: true}}, nil},
+		{"negative min and bad period", []CooldownRange{{Min: -1, Period: 0, Unbounded: true}}, []string{"cooldowns[0].min", "cooldowns[0].period"}},
+		{"inverted bounds", []CooldownRange{{Min: 100, Max: 50, Period: 1}}, []string{"cooldowns[0].max"}},
+		{"overlap", []CooldownRange{{Min: 0, Max: 1000, Period: 1}, {Min: 500, Period: 7, Unbounded: true}}, []string{"cooldowns[1].min"}},
+		{"gap", []CooldownRange{{Min: 0, Max: 1000, Period: 1}, {Min: 2000, Period: 7, Unbounded: true}}, []string{"cooldowns[1].min"}},
+		{"top is bounded", []CooldownRange{{Min: 0, Max: 1000, Period: 1}}, []string{"cooldowns[0].unbounded"}},
+		// ошибки указывают исходные индексы, даже если диапазоны пришли не по порядку
+		{"open range in the middle", []CooldownRange{{Min: 1001, Max: 5000, Period: 7}, {Min: 0, Period: 1, Unbounded: true}},
+			[]string{"cooldowns[1].unbounded", "cooldowns[0].unbounded"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, e := range validateCooldowns(tt.ranges) {
+				fields = append(fields, e.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("validateCooldowns() fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestNormalizeCooldowns(t *testing.T) {
+	in := []CooldownRange{
+		{Min: 20000, Max: 10000, Period: 14},
+		{Min: 0, Max: 5000, Period: 1},
+		{Min: 4000, Max: 8000, Period: 3},
+		{Min: 100, Max: 200, Period: 2},
+		{Min: 30000, Max: 50000, Period: 500},
+	}
+	got, fixes := normalizeCooldowns(in)
+	if errs := validateCooldowns(got); len(errs) != 0 {
+		t.Fatalf("normalized ranges are still invalid: %v\n%v", errs, got)
+	}
+	want := []CooldownRange{
+		{Min: 0, Max: 5000, Period: 1},
+		{Min: 5001, Max: 9999, Period: 3},
+		{Min: 10000, Max: 29999, Period: 14},
+		{Min: 30000, Period: maxCoolingDays, Unbounded: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeCooldowns() = %v, want %v", got, want)
+	}
+	if len(fixes) == 0 {
+		t.Error("normalizeCooldowns() reported no fixes")
+	}
+
+	if got, fixes := normalizeCooldowns(want); !reflect.DeepEqual(got, want) || len(fixes) != 0 {
+		t.Errorf("valid ranges changed: %v, fixes %v", got, fixes)
+	}
+}
+
+func TestPresetCooldownsInProfileCurrency(t *testing.T) {
+	preset := cooldownPresets["balanced"]
+	rates := DefaultRates()
+
+	rub, err := presetCooldowns(preset, "RUB", rates)
+	if err != nil || !reflect.DeepEqual(rub, preset.Cooldowns) {
+		t.Fatalf("RUB preset = %v, %v; want %v", rub, err, preset.Cooldowns)
+	}
+
+	usd, err := presetCooldowns(preset, "USD", rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := validateCooldowns(usd); len(errs) != 0 {
+		t.Fatalf("converted preset is invalid: %v\n%v", errs, usd)
+	}
+	rate, _ := rates.Rate("RUB", "USD")
+	if want := math.Round(15000 * rate); usd[0].Max != want {
+		t.Errorf("first USD bound = %v, want %v", usd[0].Max, want)
+	}
+	if preset.Cooldowns[0].Max != 15000 {
+		t.Error("presetCooldowns modified the built-in preset")
+	}
+}
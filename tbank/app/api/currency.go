@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// defaultCurrency — валюта профиля и желаний по умолчанию
+const defaultCurrency = "RUB"
+
+// errUnknownCurrency — курс для валюты неизвестен
+var errUnknownCurrency = errors.New("unknown currency")
+
+// RateProvider отдает курсы валют
+type RateProvider interface {
+	// Rate возвращает, сколько единиц to стоит одна единица from
+	Rate(from, to string) (float64, error)
+}
+
+// StaticRates — фиксированная таблица курсов относительно базовой валюты.
+// Работает без сети: таблица зашита в код или читается из файла.
+type StaticRates struct {
+	// Base — базовая валюта таблицы
+	Base string `json:"base"`
+	// Rates — стоимость одной единицы валюты в базовой валюте
+	Rates map[string]float64 `json:"rates"`
+}
+
+// DefaultRates возвращает встроенную таблицу курсов к рублю
+func DefaultRates() StaticRates {
+	return StaticRates{
+		Base: "RUB",
+		Rates: map[string]float64{
+			"RUB": 1,
+			"USD": 92,
+			"EUR": 100,
+			"GBP": 117,
+			"CNY": 12.7,
+			"KZT": 0.19,
+			"BYN": 28,
+			"TRY": 2.7,
+			"AED": 25,
+			"JPY": 0.61,
+		},
+	}
+}
+
+// LoadRatesFile читает таблицу курсов из JSON-файла вида {"base":"RUB","rates":{"USD":92}}
+func LoadRatesFile(path string) (StaticRates, error) {
+	var r StaticRates
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("rates file %s: %w", path, err)
+	}
+
+	r.Base = normalizeCurrency(r.Base)
+	if r.Base == "" {
+		r.Base = defaultCurrency
+	}
+	rates := make(map[string]float64, len(r.Rates)+1)
+	for code, v := range r.Rates {
+		if v <= 0 {
+			return r, fmt.Errorf("rates file %s: rate for %s must be positive", path, code)
+		}
+		rates[normalizeCurrency(code)] = v
+	}
+	rates[r.Base] = 1
+	r.Rates = rates
+	return r, nil
+}
+
+// Rate возвращает курс from -> to через базовую валюту таблицы
+func (r StaticRates) Rate(from, to string) (float64, error) {
+	f, ok := r.Rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errUnknownCurrency, from)
+	}
+	t, ok := r.Rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errUnknownCurrency, to)
+	}
+	return f / t, nil
+}
+
+// normalizeCurrency приводит код валюты к виду ISO 4217 (верхний регистр)
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// profileCurrency возвращает базовую валюту профиля
+func profileCurrency(p UserProfile) string {
+	if c := normalizeCurrency(p.Currency); c != "" {
+		return c
+	}
+	return defaultCurrency
+}
+
+// convertWish пересчитывает цену желания в базовую валюту профиля
+func convertWish(w *Wish, base string, rates RateProvider) error {
+	w.Currency = normalizeCurrency(w.Currency)
+	if w.Currency == "" {
+		w.Currency = base
+	}
+	rate, err := rates.Rate(w.Currency, base)
+	if err != nil {
+		return err
+	}
+	w.BaseCurrency = base
+	w.ConvertedPrice = math.Round(w.Price*rate*100) / 100
+	return nil
+}
+
+// currencySymbols — символы для вывода сумм в уведомлениях и календаре
+var currencySymbols = map[string]string{"RUB": "₽", "USD": "$", "EUR": "€", "GBP": "£", "CNY": "¥", "JPY": "¥"}
+
+// formatMoney форматирует сумму с символом или кодом валюты
+func formatMoney(amount float64, currency string) string {
+	if sym, ok := currencySymbols[currency]; ok {
+		return formatFloat(amount) + " " + sym
+	}
+	return formatFloat(amount) + " " + currency
+}
@@ -15,13 +15,21 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/users/{userId}/settings": {
+        "/calendar/{userId}": {
             "get": {
-                "description": "Возвращает настройки пользователя по его ID",
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Возвращает текущую ссылку на iCalendar-ленту пользователя",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "settings"
+                    "calendar"
                 ],
-                "summary": "Получить настройки пользователя",
+                "summary": "Получить ссылку на календарь",
                 "parameters": [
                     {
                         "type": "string",
@@ -35,17 +43,90 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.Settings"
+                            "$ref": "#/definitions/main.CalendarFeed"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Сохраняет настройки пользователя по его ID",
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Выдает новый токен iCalendar-ленты, предыдущая ссылка перестает работать",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Выдать ссылку на календарь",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.CalendarFeed"
+                        }
+                    }
+                }
+            }
+        },
+        "/cooldown-presets": {
+            "get": {
+                "description": "Возвращает встроенные наборы диапазонов охлаждения",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "settings"
                 ],
-                "summary": "Сохранить настройки пользователя",
+                "summary": "Пресеты охлаждения",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.CooldownPreset"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/export/{userId}": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Выгружает активные, выполненные и отмененные желания вместе с профилем и настройками в CSV, JSON или XLSX",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "export"
+                ],
+                "summary": "Выгрузить историю желаний",
                 "parameters": [
                     {
                         "type": "string",
@@ -55,32 +136,83 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Настройки",
-                        "name": "settings",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "default": "json",
+                        "description": "Формат выгрузки: csv, json, xlsx",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/main.Settings"
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
                         }
                     }
+                }
+            }
+        },
+        "/ical/{token}.ics": {
+            "get": {
+                "description": "Лента с датами окончания охлаждения активных желаний и повторяющимися опросами",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "iCalendar-лента",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Токен ленты",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "успешно сохранено",
+                        "description": "VCALENDAR",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
                     }
                 }
             }
         },
-        "/users/{userId}/wishes": {
-            "get": {
-                "description": "Возвращает все желания пользователя по его ID",
+        "/import/{userId}": {
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Загружает желания из CSV или JSON. Каждая строка проверяется так же, как при добавлении одного желания. При dryRun=true возвращаются только вердикты; иначе пачка сохраняется целиком или не сохраняется вовсе.",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "wishes"
+                    "import"
                 ],
-                "summary": "Получить список желаний пользователя",
+                "summary": "Импортировать желания",
                 "parameters": [
                     {
                         "type": "string",
@@ -88,26 +220,66 @@ const docTemplate = `{
                         "name": "userId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv или json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только проверить",
+                        "name": "dryRun",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/main.Wish"
-                            }
+                            "$ref": "#/definitions/main.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "bad_request или invalid_json",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "payload_too_large",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/main.ImportResult"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/notify/{userId}": {
             "post": {
-                "description": "Добавляет новое желание пользователя",
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Отправляет уведомление пользователю по его ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "wishes"
+                    "notify"
                 ],
-                "summary": "Добавить желание",
+                "summary": "Отправить уведомление пользователю",
                 "parameters": [
                     {
                         "type": "string",
@@ -117,32 +289,49 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Желание",
-                        "name": "wish",
+                        "description": "Объект уведомления",
+                        "name": "notification",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.Wish"
+                            "$ref": "#/definitions/main.Notification"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "успешно добавлено",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
                         }
                     }
                 }
             }
         },
-        "/users/{userId}/wishes/{wishId}": {
-            "delete": {
-                "description": "Удаляет желание пользователя по его ID",
+        "/planner/{userId}": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Распределяет сбережения и ежемесячные накопления по активным желаниям в порядке приоритета",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "wishes"
+                    "planner"
                 ],
-                "summary": "Удалить желание",
+                "summary": "План накоплений",
                 "parameters": [
                     {
                         "type": "string",
@@ -150,32 +339,123 @@ const docTemplate = `{
                         "name": "userId",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.PlanItem"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/session": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Возвращает пользователя, за которым закреплен токен",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Текущая сессия",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.User"
+                        }
                     },
+                    "401": {
+                        "description": "sign in required",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Выдает подписанный токен сессии для ника. Новый ник регистрируется. Если у ника включен PIN, он обязателен.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Войти по нику",
+                "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID желания",
-                        "name": "wishId",
-                        "in": "path",
-                        "required": true
+                        "description": "Ник и PIN",
+                        "name": "credentials",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.SignInRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "успешно удалено",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/main.Session"
+                        }
+                    },
+                    "401": {
+                        "description": "pin required",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "too many wrong pins",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
                         }
                     }
                 }
+            },
+            "delete": {
+                "description": "Удаляет cookie сессии. Чтобы отозвать все токены, смените PIN.",
+                "tags": [
+                    "session"
+                ],
+                "summary": "Выйти",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
             }
         },
-        "/users/{userId}/wishes/{wishId}/toggle": {
-            "post": {
-                "description": "Переключает статус StillWant для желания по его ID",
+        "/settings/{userId}": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Возвращает настройки по ID пользователя; токены и пароли заменены маской",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "wishes"
+                    "settings"
                 ],
-                "summary": "Переключить статус желания",
+                "summary": "Получить настройки пользователя",
                 "parameters": [
                     {
                         "type": "string",
@@ -183,49 +463,1931 @@ const docTemplate = `{
                         "name": "userId",
                         "in": "path",
                         "required": true
-                    },
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Settings"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Обновляет настройки по ID пользователя и пересчитывает охлаждение активных желаний",
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Сохранить настройки пользователя",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "ID желания",
-                        "name": "wishId",
+                        "description": "ID пользователя",
+                        "name": "userId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Объект настроек",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Settings"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "успешно обновлено",
+                        "description": "сохранено, тело пустое"
+                    },
+                    "400": {
+                        "description": "validation_failed",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/main.Problem"
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "main.CooldownRange": {
-            "description": "Диапазон охлаждения для настроек.",
-            "type": "object",
-            "properties": {
-                "max": {
-                    "description": "Max — максимальное значение\nexample: 10000",
-                    "type": "number"
-                },
-                "min": {
+            },
+            "patch": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Применяет JSON Merge Patch (RFC 7396): переданные поля заменяются, null сбрасывает поле, остальные не меняются. Охлаждение активных желаний пересчитывается",
+                "consumes": [
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Частично изменить настройки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.Settings"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Settings"
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/{userId}/cooldowns/normalize": {
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Проверяет диапазоны из тела запроса (или сохраненные, если тело пустое) и предлагает исправленный набор. Настройки не меняются.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Исправить диапазоны охлаждения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Диапазоны",
+                        "name": "cooldowns",
+                        "in": "body",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.CooldownRange"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.CooldownProposal"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid_json",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/{userId}/cooldowns/preset/{name}": {
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Заменяет диапазоны охлаждения пользователя пресетом, пересчитав границы в валюту профиля; правила категорий сохраняются, охлаждение активных желаний пересчитывается",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Применить пресет охлаждения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "strict, balanced или relaxed",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Settings"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown preset",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/{userId}/history": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Версии настроек пользователя, новые в конце. Токены и пароли скрыты.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "История настроек",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.SettingsVersion"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/{userId}/history/{version}/rollback": {
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Восстанавливает версию настроек как новую версию и пересчитывает охлаждение активных желаний",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Откатить настройки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер версии",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Settings"
+                        }
+                    },
+                    "404": {
+                        "description": "version not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/survey/{userId}": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Активные желания в порядке опроса: сначала с законченным охлаждением, затем по приоритету и желаемой дате",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "planner"
+                ],
+                "summary": "Очередь опроса",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.SurveyItem"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{nick}": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Возвращает профиль по нику пользователя",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Получить профиль пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ник пользователя",
+                        "name": "nick",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.UserProfile"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Сохраняет профиль по нику пользователя",
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Сохранить профиль пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ник пользователя",
+                        "name": "nick",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Объект профиля",
+                        "name": "profile",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.UserProfile"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "сохранено, тело пустое"
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Применяет JSON Merge Patch (RFC 7396) к профилю; ник берется из пути",
+                "consumes": [
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Частично изменить профиль",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ник пользователя",
+                        "name": "nick",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.UserProfile"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.UserProfile"
+                        }
+                    },
+                    "400": {
+                        "description": "validation_failed",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{nick}/history": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Версии профиля пользователя, новые в конце",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "История профиля",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ник пользователя",
+                        "name": "nick",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.ProfileVersion"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{nick}/history/{version}/rollback": {
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Восстанавливает версию профиля как новую версию и пересчитывает активные желания",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Откатить профиль",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ник пользователя",
+                        "name": "nick",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер версии",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.UserProfile"
+                        }
+                    },
+                    "404": {
+                        "description": "version not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "post": {
+                "description": "Создает пользователя с уникальным ником и необязательным телефоном",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Зарегистрировать пользователя",
+                "parameters": [
+                    {
+                        "description": "Ник и телефон",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.UserInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/main.User"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "nick is already taken",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{ref}": {
+            "get": {
+                "description": "Находит пользователя по ID или нику (без учета регистра). Телефон виден только самому пользователю.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID или ник",
+                        "name": "ref",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.User"
+                        }
+                    },
+                    "404": {
+                        "description": "user not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Меняет ник и/или телефон. Желания, настройки и профиль привязаны к ID и сохраняются.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Изменить ник или телефон",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID или ник",
+                        "name": "ref",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новые ник и телефон",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.UserInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.User"
+                        }
+                    },
+                    "403": {
+                        "description": "session belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "user not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "nick is already taken",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{ref}/pin": {
+            "put": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Защищает ник PIN-кодом. Если PIN уже включен, нужен текущий. Старые токены перестают действовать, в ответе — новая сессия.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Включить или сменить PIN",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID или ник",
+                        "name": "ref",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новый и текущий PIN",
+                        "name": "pin",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PINRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Session"
+                        }
+                    },
+                    "401": {
+                        "description": "wrong pin",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "session belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Снимает защиту PIN-кодом; нужен текущий PIN. В ответе — новая сессия.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Отключить PIN",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID или ник",
+                        "name": "ref",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Текущий PIN",
+                        "name": "pin",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.PINRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Session"
+                        }
+                    },
+                    "401": {
+                        "description": "wrong pin",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "session belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishes/{userId}": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Возвращает желания пользователя с поиском, фильтрами, сортировкой и курсорной пагинацией. Общее количество — в заголовке X-Total-Count, курсор следующей страницы — в X-Next-Cursor.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Получить список желаний пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "completed",
+                            "canceled"
+                        ],
+                        "type": "string",
+                        "description": "Статус",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Поиск по названию",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Категория",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Метка",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Минимальная цена",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Максимальная цена",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Создано не раньше (RFC 3339 или YYYY-MM-DD)",
+                        "name": "createdFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Создано не позже (RFC 3339 или YYYY-MM-DD)",
+                        "name": "createdTo",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created",
+                            "price",
+                            "comfort",
+                            "priority"
+                        ],
+                        "type": "string",
+                        "description": "Поле сортировки",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Порядок",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (до 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Wish"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Курсор следующей страницы"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Всего подходящих желаний"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Создает новое желание для пользователя. Если среди активных и недавно отмененных\nесть желания с той же ссылкой или похожим названием, они возвращаются в duplicates.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Добавить желание",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новое желание",
+                        "name": "wish",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.WishInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.AddWishResult"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishes/{userId}/{wishId}": {
+            "put": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Выполняет действие над желанием: complete, cancel или reopen",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Сменить статус желания по действию",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID желания",
+                        "name": "wishId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "complete",
+                            "cancel",
+                            "reopen"
+                        ],
+                        "type": "string",
+                        "description": "Действие",
+                        "name": "action",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "unknown action",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "illegal status transition",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Удаляет желание пользователя по его ID",
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Удалить желание",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID желания",
+                        "name": "wishId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "удалено"
+                    },
+                    "404": {
+                        "description": "wish_not_found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Меняет название, цену, категорию, заметки и ссылку; пересчитывает охлаждение и комфорт и пишет историю изменений",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Изменить желание",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID желания",
+                        "name": "wishId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.WishPatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishes/{userId}/{wishId}/merge": {
+            "post": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Переносит в желание данные дублей и удаляет их. Объединяются только активные желания; если меток или заметок получается больше допустимого, объединение отклоняется. Охлаждение считается от самой ранней даты создания.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Объединить дубли",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID желания, которое останется",
+                        "name": "wishId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Дубли",
+                        "name": "merge",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.MergeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "ошибка валидации",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "only active wishes can be merged",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "merged wish is invalid",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishes/{userId}/{wishId}/prices": {
+            "get": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Возвращает наблюдения цены товара по ссылке желания",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "История цены",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID желания",
+                        "name": "wishId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.PricePoint"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/wishes/{userId}/{wishId}/status": {
+            "put": {
+                "security": [
+                    {
+                        "SessionToken": []
+                    }
+                ],
+                "description": "Переводит желание в указанный статус, если переход разрешен",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wishes"
+                ],
+                "summary": "Сменить статус желания",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID желания",
+                        "name": "wishId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новый статус",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.StatusChange"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.Wish"
+                        }
+                    },
+                    "400": {
+                        "description": "unknown status",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "not found",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "illegal status transition",
+                        "schema": {
+                            "$ref": "#/definitions/main.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "main.AddWishResult": {
+            "description": "Созданное желание; duplicates есть, только если найдены похожие.",
+            "type": "object",
+            "properties": {
+                "baseCurrency": {
+                    "description": "BaseCurrency — базовая валюта профиля на момент пересчета\nexample: \"RUB\"",
+                    "type": "string"
+                },
+                "category": {
+                    "description": "Category — категория желания\nexample: \"Электроника\"",
+                    "type": "string"
+                },
+                "comfortMonths": {
+                    "description": "ComfortMonths — количество месяцев комфорта для желания\nexample: 3",
+                    "type": "integer"
+                },
+                "convertedPrice": {
+                    "description": "ConvertedPrice — цена в базовой валюте профиля; по ней считаются охлаждение и комфорт\nexample: 920000",
+                    "type": "number"
+                },
+                "coolingDays": {
+                    "description": "CoolingDays — количество дней на \"остывание\"\nexample: 5",
+                    "type": "integer"
+                },
+                "coolingStartedAt": {
+                    "description": "CoolingStartedAt — момент (пере)запуска охлаждения; пусто — считается от CreatedAt\nexample: \"2024-01-03T10:00:00Z\"",
+                    "type": "string"
+                },
+                "createdAt": {
+                    "description": "CreatedAt — дата и время создания желания\nexample: \"2024-01-01T12:00:00Z\"",
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "Currency — валюта цены (ISO 4217)\nexample: \"USD\"",
+                    "type": "string"
+                },
+                "duplicates": {
+                    "description": "Duplicates — похожие желания",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.DuplicateMatch"
+                    }
+                },
+                "edits": {
+                    "description": "Edits — история изменений желания",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.WishEdit"
+                    }
+                },
+                "id": {
+                    "description": "ID — уникальный идентификатор\nexample: \"123e4567-e89b-12d3-a456-426614174000\"",
+                    "type": "string"
+                },
+                "notes": {
+                    "description": "Notes — заметка в markdown: почему хочется\nexample: \"Старый **уже тормозит**\"",
+                    "type": "string"
+                },
+                "price": {
+                    "description": "Price — цена в валюте желания\nexample: 10 000",
+                    "type": "number"
+                },
+                "priority": {
+                    "description": "Priority — приоритет\nexample: \"high\"",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishPriority"
+                        }
+                    ]
+                },
+                "recommendedCooling": {
+                    "description": "RecommendedCooling — рекомендованное количество дней на \"остывание\"\nexample: 7",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Status — статус желания\nexample: \"active\"",
+                    "enum": [
+                        "active",
+                        "completed",
+                        "canceled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishStatus"
+                        }
+                    ]
+                },
+                "statusChangedAt": {
+                    "description": "StatusChangedAt — дата и время последней смены статуса\nexample: \"2024-01-04T09:00:00Z\"",
+                    "type": "string"
+                },
+                "stillWant": {
+                    "description": "StillWant — флаг, указывающий, хочет ли пользователь всё ещё это желание\nexample: true",
+                    "type": "boolean"
+                },
+                "tags": {
+                    "description": "Tags — произвольные метки\nexample: [\"работа\",\"подарок\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "targetDate": {
+                    "description": "TargetDate — желаемая дата покупки\nexample: \"2024-06-01T00:00:00Z\"",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "Title — название желания\nexample: \"Новый Ноутбук\"",
+                    "type": "string"
+                },
+                "updateAt": {
+                    "description": "UpdateAt — дата и время последнего обновления желания\nexample: \"2024-01-05T15:30:00Z\"",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL — ссылка на товар\nexample: \"https://example.com/laptop\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.CalendarFeed": {
+            "description": "Ссылка для подписки на календарь.",
+            "type": "object",
+            "properties": {
+                "token": {
+                    "description": "Token — секретный токен ленты\nexample: \"9f86d081884c7d659a2feaa0c55ad015\"",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL — адрес ленты для подписки\nexample: \"http://localhost:8080/api/v1/ical/9f86d081884c7d659a2feaa0c55ad015.ics\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.CategoryCooling": {
+            "description": "Охлаждение для категории не короче MinDays и не длиннее MaxDays.",
+            "type": "object",
+            "properties": {
+                "category": {
+                    "description": "Category — категория (без учета регистра)\nexample: \"Электроника\"",
+                    "type": "string"
+                },
+                "maxDays": {
+                    "description": "MaxDays — максимальное охлаждение в днях; 0 — без ограничения\nexample: 0",
+                    "type": "integer"
+                },
+                "minDays": {
+                    "description": "MinDays — минимальное охлаждение в днях; 0 — без ограничения\nexample: 14",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.CooldownPreset": {
+            "description": "Именованный набор диапазонов охлаждения.",
+            "type": "object",
+            "properties": {
+                "cooldowns": {
+                    "description": "Cooldowns — диапазоны охлаждения",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.CooldownRange"
+                    }
+                },
+                "currency": {
+                    "description": "Currency — валюта границ; при применении они пересчитываются в валюту профиля\nexample: \"RUB\"",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name — имя пресета\nexample: \"balanced\"",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "Title — название для интерфейса\nexample: \"Сбалансированный\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.CooldownProposal": {
+            "description": "Предложенные диапазоны охлаждения и список исправлений.",
+            "type": "object",
+            "properties": {
+                "cooldowns": {
+                    "description": "Cooldowns — исправленные диапазоны",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.CooldownRange"
+                    }
+                },
+                "errors": {
+                    "description": "Errors — ошибки исходных диапазонов",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldError"
+                    }
+                },
+                "fixes": {
+                    "description": "Fixes — что было исправлено\nexample: [\"cooldowns[1]: min 14000 -\u003e 15001 to remove overlap\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "main.CooldownRange": {
+            "description": "Диапазон охлаждения для настроек.",
+            "type": "object",
+            "properties": {
+                "max": {
+                    "description": "Max — максимальное значение включительно; не учитывается, если Unbounded\nexample: 10000",
+                    "type": "number"
+                },
+                "min": {
                     "description": "Min — минимальное значение\nexample: 1",
                     "type": "number"
                 },
-                "period": {
-                    "description": "Period — период охлаждения в днях\nexample: 7",
+                "period": {
+                    "description": "Period — период охлаждения в днях\nexample: 7",
+                    "type": "integer"
+                },
+                "unbounded": {
+                    "description": "Unbounded — «потолка нет»: диапазон открыт сверху, допустим только у верхнего диапазона\nexample: false",
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.DuplicateMatch": {
+            "description": "Похожее желание среди активных и недавно отмененных.",
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Reason — почему желание похоже: url или title\nexample: \"url\"",
+                    "type": "string"
+                },
+                "similarity": {
+                    "description": "Similarity — похожесть названий от 0 до 1\nexample: 0.91",
+                    "type": "number"
+                },
+                "wish": {
+                    "$ref": "#/definitions/main.Wish"
+                }
+            }
+        },
+        "main.FieldChange": {
+            "description": "Старое и новое значение поля.",
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field — имя поля\nexample: \"price\"",
+                    "type": "string"
+                },
+                "from": {
+                    "description": "From — старое значение\nexample: \"10000\"",
+                    "type": "string"
+                },
+                "to": {
+                    "description": "To — новое значение\nexample: \"12000\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.FieldError": {
+            "description": "Ошибка валидации поля.",
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field — путь к полю\nexample: \"cooldowns[1].min\"",
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Message — описание ошибки на языке из Accept-Language\nexample: \"overlaps with cooldowns[0]\"",
+                    "type": "string"
+                },
+                "param": {
+                    "description": "Param — параметр правила\nexample: \"200\"",
+                    "type": "string"
+                },
+                "rule": {
+                    "description": "Rule — нарушенное правило валидации, если ошибка найдена по тегу validate\nexample: \"maxlen\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.ImportResult": {
+            "description": "Результат импорта желаний.",
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Applied — пачка сохранена",
+                    "type": "boolean"
+                },
+                "dryRun": {
+                    "description": "DryRun — проверка без сохранения",
+                    "type": "boolean"
+                },
+                "rows": {
+                    "description": "Rows — вердикты по строкам",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.ImportVerdict"
+                    }
+                },
+                "total": {
+                    "description": "Total — всего строк",
+                    "type": "integer"
+                },
+                "valid": {
+                    "description": "Valid — строк без ошибок",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ImportVerdict": {
+            "description": "Вердикт по строке импорта.",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error — причина отказа\nexample: \"price must be positive\"",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors — ошибки по полям, если строка не прошла правила validate",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldError"
+                    }
+                },
+                "ok": {
+                    "description": "OK — строка прошла проверку\nexample: true",
+                    "type": "boolean"
+                },
+                "row": {
+                    "description": "Row — номер строки (с единицы, без заголовка)\nexample: 1",
+                    "type": "integer"
+                },
+                "wish": {
+                    "description": "Wish — желание, которое будет создано",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.Wish"
+                        }
+                    ]
+                }
+            }
+        },
+        "main.MergeRequest": {
+            "description": "ID дублей, которые будут удалены после объединения.",
+            "type": "object",
+            "required": [
+                "duplicateIds"
+            ],
+            "properties": {
+                "duplicateIds": {
+                    "description": "DuplicateIDs — ID дублей\nexample: [\"dm8fd1nh2ivg905\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "main.Notification": {
+            "type": "object",
+            "required": [
+                "title"
+            ],
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.PINRequest": {
+            "description": "Новый PIN и текущий, если PIN уже включен.",
+            "type": "object",
+            "properties": {
+                "currentPin": {
+                    "description": "CurrentPIN — текущий PIN\nexample: \"0000\"",
+                    "type": "string"
+                },
+                "pin": {
+                    "description": "PIN — новый PIN из 4-8 цифр\nexample: \"1234\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.PlanItem": {
+            "description": "Когда хватит денег на желание при накоплении по приоритетам.",
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "Currency — валюта профиля\nexample: \"RUB\"",
+                    "type": "string"
+                },
+                "fundedAt": {
+                    "description": "FundedAt — дата, когда хватит денег",
+                    "type": "string"
+                },
+                "meetsTarget": {
+                    "description": "MeetsTarget — успеваем к желаемой дате",
+                    "type": "boolean"
+                },
+                "months": {
+                    "description": "Months — через сколько месяцев хватит денег; -1 — недостижимо\nexample: 3",
+                    "type": "integer"
+                },
+                "price": {
+                    "description": "Price — цена в валюте профиля",
+                    "type": "number"
+                },
+                "priority": {
+                    "description": "Priority — приоритет",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishPriority"
+                        }
+                    ]
+                },
+                "targetDate": {
+                    "description": "TargetDate — желаемая дата покупки",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "Title — название желания",
+                    "type": "string"
+                },
+                "wishId": {
+                    "description": "WishID — ID желания",
+                    "type": "string"
+                }
+            }
+        },
+        "main.PricePoint": {
+            "description": "Цена товара в момент проверки.",
+            "type": "object",
+            "properties": {
+                "at": {
+                    "description": "At — дата и время проверки\nexample: \"2024-01-02T10:00:00Z\"",
+                    "type": "string"
+                },
+                "price": {
+                    "description": "Price — цена\nexample: 9500",
+                    "type": "number"
+                }
+            }
+        },
+        "main.Problem": {
+            "description": "Ошибка API (application/problem+json).",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code — машиночитаемый код ошибки\nexample: \"validation_failed\"",
+                    "type": "string"
+                },
+                "detail": {
+                    "description": "Detail — описание конкретного случая\nexample: \"title is required\"",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors — ошибки по полям",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "Instance — путь запроса\nexample: \"/api/v1/wishes/TestMeowUser\"",
+                    "type": "string"
+                },
+                "requestId": {
+                    "description": "RequestID — ID запроса, он же в заголовке X-Request-ID\nexample: \"5f0c1e9a7b3d4c2e\"",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status — HTTP-статус\nexample: 400",
+                    "type": "integer"
+                },
+                "title": {
+                    "description": "Title — краткое описание типа ошибки\nexample: \"Bad Request\"",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type — ссылка на описание типа ошибки\nexample: \"/errors/validation_failed\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.ProfileVersion": {
+            "description": "Версия профиля: снимок и отличия от предыдущей.",
+            "type": "object",
+            "properties": {
+                "at": {
+                    "description": "At — дата и время сохранения",
+                    "type": "string"
+                },
+                "changes": {
+                    "description": "Changes — отличия от предыдущей версии",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldChange"
+                    }
+                },
+                "profile": {
+                    "description": "Profile — снимок профиля",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.UserProfile"
+                        }
+                    ]
+                },
+                "rolledBackFrom": {
+                    "description": "RolledBackFrom — номер версии, к которой откатились; 0 — обычное сохранение\nexample: 0",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Version — номер версии\nexample: 2",
                     "type": "integer"
                 }
             }
         },
+        "main.Session": {
+            "description": "Токен сессии и пользователь, за которым он закреплен.",
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "description": "ExpiresAt — срок действия токена",
+                    "type": "string"
+                },
+                "token": {
+                    "description": "Token — токен для заголовка Authorization: Bearer или cookie twish_session",
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/main.User"
+                }
+            }
+        },
         "main.Settings": {
             "description": "Настройки пользователя.",
             "type": "object",
             "properties": {
+                "categoryCooling": {
+                    "description": "CategoryCooling — ограничения охлаждения для категорий поверх диапазонов цены",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.CategoryCooling"
+                    }
+                },
                 "cooldowns": {
                     "description": "Cooldowns — диапазоны охлаждения",
                     "type": "array",
@@ -233,29 +2395,227 @@ const docTemplate = `{
                         "$ref": "#/definitions/main.CooldownRange"
                     }
                 },
+                "email": {
+                    "description": "Email — email пользователя\nexample: test@gmail.com",
+                    "type": "string"
+                },
                 "excludedProducts": {
                     "description": "ExcludedProducts — исключённые продукты\nexample: \"алкоголь\"",
                     "type": "string"
                 },
                 "monthlySaving": {
                     "description": "MonthlySaving — ежемесячая сумма\nexample: 300",
-                    "type": "number"
+                    "type": "number",
+                    "minimum": 0
                 },
                 "notificationChannel": {
                     "description": "NotificationChannel — канал уведомлений\nexample: \"email\"",
-                    "type": "string"
+                    "type": "string",
+                    "enum": [
+                        "notifications",
+                        "telegram",
+                        "email"
+                    ]
                 },
                 "notificationFrequency": {
                     "description": "NotificationFreq — частота уведомлений\nexample: \"еженедельно\"",
                     "type": "string"
                 },
+                "smtpEmail": {
+                    "description": "SMTPEmail — email для SMTP\nexample: twish@twish.com",
+                    "type": "string"
+                },
+                "smtpPassword": {
+                    "description": "SMTPPassword — пароль для SMTP; только для записи, как telegramToken\nexample: \"s3cr3tP@ssw0rd\"",
+                    "type": "string"
+                },
+                "telegramChatId": {
+                    "description": "telegramChatId — ID чата Telegram\nexample: \"-1001234567890\"",
+                    "type": "string"
+                },
+                "telegramToken": {
+                    "description": "telegramToken — токен Telegram бота; только для записи, хранится зашифрованным,\nв ответах — \"***\"; \"***\" в запросе оставляет прежнее значение\nexample: \"123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11\"",
+                    "type": "string"
+                },
                 "totalPurchases": {
                     "description": "TotalPurchases — всего покупок\nexample: 25",
-                    "type": "integer"
+                    "type": "integer",
+                    "minimum": 0
                 },
                 "totalSpent": {
                     "description": "TotalSpent — потрачено всего\nexample: 1500",
-                    "type": "number"
+                    "type": "number",
+                    "minimum": 0
+                }
+            }
+        },
+        "main.SettingsVersion": {
+            "description": "Версия настроек: снимок и отличия от предыдущей.",
+            "type": "object",
+            "properties": {
+                "at": {
+                    "description": "At — дата и время сохранения",
+                    "type": "string"
+                },
+                "changes": {
+                    "description": "Changes — отличия от предыдущей версии",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldChange"
+                    }
+                },
+                "rolledBackFrom": {
+                    "description": "RolledBackFrom — номер версии, к которой откатились; 0 — обычное сохранение\nexample: 0",
+                    "type": "integer"
+                },
+                "settings": {
+                    "description": "Settings — снимок настроек",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.Settings"
+                        }
+                    ]
+                },
+                "version": {
+                    "description": "Version — номер версии\nexample: 3",
+                    "type": "integer"
+                }
+            }
+        },
+        "main.SignInRequest": {
+            "description": "Ник и PIN, если пользователь его включил.",
+            "type": "object",
+            "required": [
+                "nick"
+            ],
+            "properties": {
+                "nick": {
+                    "description": "Nick — ник; новый ник регистрируется\nexample: \"TestMeowUser\"",
+                    "type": "string"
+                },
+                "pin": {
+                    "description": "PIN — PIN, если включен\nexample: \"1234\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.StatusChange": {
+            "description": "Новый статус желания.",
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "status": {
+                    "description": "Status — целевой статус\nexample: \"completed\"",
+                    "enum": [
+                        "active",
+                        "completed",
+                        "canceled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishStatus"
+                        }
+                    ]
+                }
+            }
+        },
+        "main.SurveyItem": {
+            "description": "Желание в очереди опроса.",
+            "type": "object",
+            "properties": {
+                "coolingEnd": {
+                    "description": "CoolingEnd — дата окончания охлаждения",
+                    "type": "string"
+                },
+                "due": {
+                    "description": "Due — охлаждение закончилось, пора спросить\nexample: true",
+                    "type": "boolean"
+                },
+                "wish": {
+                    "$ref": "#/definitions/main.Wish"
+                }
+            }
+        },
+        "main.User": {
+            "description": "Пользователь: постоянный ID, ник и телефон.",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "CreatedAt — дата регистрации",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID — постоянный внутренний ID, не меняется при смене ника\nexample: \"usr_dm8fg9fkkuwa621\"",
+                    "type": "string"
+                },
+                "nick": {
+                    "description": "Nick — уникальный ник без учета регистра\nexample: \"TestMeowUser\"",
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Phone — телефон в формате +79991234567\nexample: \"+79991234567\"",
+                    "type": "string"
+                },
+                "pinEnabled": {
+                    "description": "PINEnabled — вход по нику защищен PIN-кодом",
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.UserInput": {
+            "description": "Ник и телефон; при изменении пустые поля не меняются.",
+            "type": "object",
+            "properties": {
+                "nick": {
+                    "description": "Nick — ник\nexample: \"TestMeowUser\"",
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Phone — телефон; \"-\" при изменении удаляет телефон\nexample: \"+7 999 123-45-67\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.UserProfile": {
+            "type": "object",
+            "properties": {
+                "blockedCategories": {
+                    "description": "BlockedCategories — заблокированные категории\nexample: [\"алкоголь\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "comfortPercent": {
+                    "description": "ComfortPercent — процент комфорта\nexample: 0.5 == 50%",
+                    "type": "number",
+                    "maximum": 1,
+                    "minimum": 0
+                },
+                "currency": {
+                    "description": "Currency — базовая валюта (ISO 4217), в ней считаются охлаждение и комфорт\nexample: \"RUB\"",
+                    "type": "string"
+                },
+                "monthlySavingProfile": {
+                    "description": "MonthlySavingProfile — ежемесячные сбережения\nexample: 5000",
+                    "type": "number",
+                    "minimum": 0
+                },
+                "nick": {
+                    "description": "Nick — никнейм пользователя\nexample: \"TestMeowUser\"",
+                    "type": "string"
+                },
+                "salary": {
+                    "description": "Salary — зарплата пользователя\nexample: 50000",
+                    "type": "number",
+                    "minimum": 0
+                },
+                "totalSavingsProfile": {
+                    "description": "TotalSavingsProfile — текущие сбережения\nexample: 20000",
+                    "type": "number",
+                    "minimum": 0
                 }
             }
         },
@@ -263,44 +2623,280 @@ const docTemplate = `{
             "description": "Информация о желании пользователя.",
             "type": "object",
             "properties": {
+                "baseCurrency": {
+                    "description": "BaseCurrency — базовая валюта профиля на момент пересчета\nexample: \"RUB\"",
+                    "type": "string"
+                },
                 "category": {
                     "description": "Category — категория желания\nexample: \"Электроника\"",
                     "type": "string"
                 },
+                "comfortMonths": {
+                    "description": "ComfortMonths — количество месяцев комфорта для желания\nexample: 3",
+                    "type": "integer"
+                },
+                "convertedPrice": {
+                    "description": "ConvertedPrice — цена в базовой валюте профиля; по ней считаются охлаждение и комфорт\nexample: 920000",
+                    "type": "number"
+                },
                 "coolingDays": {
                     "description": "CoolingDays — количество дней на \"остывание\"\nexample: 5",
                     "type": "integer"
                 },
+                "coolingStartedAt": {
+                    "description": "CoolingStartedAt — момент (пере)запуска охлаждения; пусто — считается от CreatedAt\nexample: \"2024-01-03T10:00:00Z\"",
+                    "type": "string"
+                },
                 "createdAt": {
                     "description": "CreatedAt — дата и время создания желания\nexample: \"2024-01-01T12:00:00Z\"",
                     "type": "string"
                 },
+                "currency": {
+                    "description": "Currency — валюта цены (ISO 4217)\nexample: \"USD\"",
+                    "type": "string"
+                },
+                "edits": {
+                    "description": "Edits — история изменений желания",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.WishEdit"
+                    }
+                },
                 "id": {
                     "description": "ID — уникальный идентификатор\nexample: \"123e4567-e89b-12d3-a456-426614174000\"",
                     "type": "string"
                 },
+                "notes": {
+                    "description": "Notes — заметка в markdown: почему хочется\nexample: \"Старый **уже тормозит**\"",
+                    "type": "string"
+                },
                 "price": {
-                    "description": "Price — цена в рублях\nexample: 10 000",
+                    "description": "Price — цена в валюте желания\nexample: 10 000",
                     "type": "number"
                 },
+                "priority": {
+                    "description": "Priority — приоритет\nexample: \"high\"",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishPriority"
+                        }
+                    ]
+                },
                 "recommendedCooling": {
                     "description": "RecommendedCooling — рекомендованное количество дней на \"остывание\"\nexample: 7",
                     "type": "integer"
                 },
+                "status": {
+                    "description": "Status — статус желания\nexample: \"active\"",
+                    "enum": [
+                        "active",
+                        "completed",
+                        "canceled"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishStatus"
+                        }
+                    ]
+                },
+                "statusChangedAt": {
+                    "description": "StatusChangedAt — дата и время последней смены статуса\nexample: \"2024-01-04T09:00:00Z\"",
+                    "type": "string"
+                },
                 "stillWant": {
                     "description": "StillWant — флаг, указывающий, хочет ли пользователь всё ещё это желание\nexample: true",
                     "type": "boolean"
                 },
+                "tags": {
+                    "description": "Tags — произвольные метки\nexample: [\"работа\",\"подарок\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "targetDate": {
+                    "description": "TargetDate — желаемая дата покупки\nexample: \"2024-06-01T00:00:00Z\"",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "Title — название желания\nexample: \"Новый Ноутбук\"",
+                    "type": "string"
+                },
+                "updateAt": {
+                    "description": "UpdateAt — дата и время последнего обновления желания\nexample: \"2024-01-05T15:30:00Z\"",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "URL — ссылка на товар\nexample: \"https://example.com/laptop\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.WishEdit": {
+            "description": "Одно редактирование желания.",
+            "type": "object",
+            "properties": {
+                "at": {
+                    "description": "At — дата и время изменения\nexample: \"2024-01-03T10:00:00Z\"",
+                    "type": "string"
+                },
+                "changes": {
+                    "description": "Changes — измененные поля",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.FieldChange"
+                    }
+                },
+                "coolingRestarted": {
+                    "description": "CoolingRestarted — охлаждение запущено заново\nexample: false",
+                    "type": "boolean"
+                }
+            }
+        },
+        "main.WishInput": {
+            "description": "Данные для создания желания.",
+            "type": "object",
+            "required": [
+                "title"
+            ],
+            "properties": {
+                "category": {
+                    "description": "Category — категория желания\nexample: \"Электроника\"",
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "Currency — валюта цены (ISO 4217); пусто — валюта профиля\nexample: \"USD\"",
+                    "type": "string"
+                },
+                "notes": {
+                    "description": "Notes — заметка в markdown\nexample: \"Старый **уже тормозит**\"",
+                    "type": "string"
+                },
+                "price": {
+                    "description": "Price — цена\nexample: 10000",
+                    "type": "number"
+                },
+                "priority": {
+                    "description": "Priority — приоритет: low, normal, high\nexample: \"normal\"",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishPriority"
+                        }
+                    ]
+                },
+                "tags": {
+                    "description": "Tags — произвольные метки\nexample: [\"работа\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "targetDate": {
+                    "description": "TargetDate — желаемая дата покупки, YYYY-MM-DD или RFC 3339\nexample: \"2024-06-01\"",
+                    "type": "string"
+                },
                 "title": {
                     "description": "Title — название желания\nexample: \"Новый Ноутбук\"",
                     "type": "string"
+                },
+                "url": {
+                    "description": "URL — ссылка на товар\nexample: \"https://example.com/laptop\"",
+                    "type": "string"
+                }
+            }
+        },
+        "main.WishPatch": {
+            "description": "Частичное изменение желания.",
+            "type": "object",
+            "required": [
+                "title"
+            ],
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "priority": {
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/main.WishPriority"
+                        }
+                    ]
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "targetDate": {
+                    "description": "TargetDate — YYYY-MM-DD или RFC 3339; пустая строка снимает дату",
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
                 }
             }
+        },
+        "main.WishPriority": {
+            "type": "string",
+            "enum": [
+                "low",
+                "normal",
+                "high"
+            ],
+            "x-enum-varnames": [
+                "PriorityLow",
+                "PriorityNormal",
+                "PriorityHigh"
+            ]
+        },
+        "main.WishStatus": {
+            "type": "string",
+            "enum": [
+                "active",
+                "completed",
+                "canceled"
+            ],
+            "x-enum-varnames": [
+                "StatusActive",
+                "StatusCompleted",
+                "StatusCanceled"
+            ]
         }
     },
     "securityDefinitions": {
-        "BasicAuth": {
-            "type": "basic"
+        "SessionToken": {
+            "description": "Токен из POST /session в виде \"Bearer \u003ctoken\u003e\"; вместо заголовка можно передать cookie twish_session",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -309,10 +2905,10 @@ const docTemplate = `{
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8080",
-	BasePath:         "/",
+	BasePath:         "/api/v1",
 	Schemes:          []string{},
 	Title:            "TWish API",
-	Description:      "MeowMeow ./<|<3|>\\.",
+	Description:      "MeowMeow ./<|<3|>.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
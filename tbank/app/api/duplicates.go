@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// duplicateTitleThreshold — минимальная похожесть названий, при которой желания считаются дублями
+const duplicateTitleThreshold = 0.8
+
+// duplicateCanceledWindow — сколько времени отмененное желание еще участвует в поиске дублей
+const duplicateCanceledWindow = 30 * 24 * time.Hour
+
+var (
+	// errMergeSelf — желание нельзя объединить само с собой
+	errMergeSelf = errors.New("wish can't be merged with itself")
+	// errMergeInactive — объединять можно только активные желания
+	errMergeInactive = errors.New("only active wishes can be merged")
+	// errMergeInvalid — объединенное желание не проходит validateWish, например меток больше maxTags
+	errMergeInvalid = errors.New("merged wish is invalid")
+)
+
+// trackingParams — параметры ссылки, которые не влияют на товар
+var trackingParams = map[string]bool{"fbclid": true, "gclid": true, "yclid": true, "ref": true, "from": true}
+
+// DuplicateMatch — возможный дубль нового желания
+// @Description Похожее желание среди активных и недавно отмененных.
+type DuplicateMatch struct {
+	Wish Wish `json:"wish"`
+	// Reason — почему желание похоже: url или title
+	// example: "url"
+	Reason string `json:"reason"`
+	// Similarity — похожесть названий от 0 до 1
+	// example: 0.91
+	Similarity float64 `json:"similarity"`
+}
+
+// AddWishResult — созданное желание и возможные дубли
+// @Description Созданное желание; duplicates есть, только если найдены похожие.
+type AddWishResult struct {
+	Wish
+	// Duplicates — похожие желания
+	Duplicates []DuplicateMatch `json:"duplicates,omitempty"`
+}
+
+// MergeRequest — желания, которые нужно объединить с целевым
+// @Description ID дублей, которые будут удалены после объединения.
+type MergeRequest struct {
+	// DuplicateIDs — ID дублей
+	// example: ["dm8fd1nh2ivg905"]
+	DuplicateIDs []string `json:"duplicateIds" validate:"required,maxlen=50"`
+}
+
+// normalizeWishURL приводит ссылку на товар к виду для сравнения:
+// без схемы, www, фрагмента, utm- и прочих трекинговых параметров и завершающего слеша
+func normalizeWishURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	q := u.Query()
+	for k := range q {
+		if strings.HasPrefix(strings.ToLower(k), "utm_") || trackingParams[strings.ToLower(k)] {
+			q.Del(k)
+		}
+	}
+	norm := host + strings.TrimRight(u.EscapedPath(), "/")
+	if enc := q.Encode(); enc != "" {
+		norm += "?" + enc
+	}
+	return norm
+}
+
+// titleBigrams разбивает название на пары соседних символов по словам без учета регистра и пунктуации
+func titleBigrams(title string) []string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+
+	var grams []string
+	for _, w := range words {
+		rs := []rune(w)
+		if len(rs) == 1 {
+			grams = append(grams, w)
+			continue
+		}
+		for i := 0; i+1 < len(rs); i++ {
+			grams = append(grams, string(rs[i:i+2]))
+		}
+	}
+	return grams
+}
+
+// titleSimilarity — коэффициент Сёренсена — Дайса по биграммам названий, от 0 до 1.
+// Устойчив к опечаткам, регистру и порядку слов.
+func titleSimilarity(a, b string) float64 {
+	ga, gb := titleBigrams(a), titleBigrams(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(ga))
+	for _, g := range ga {
+		counts[g]++
+	}
+	common := 0
+	for _, g := range gb {
+		if counts[g] > 0 {
+			counts[g]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(ga)+len(gb))
+}
+
+// findDuplicates ищет среди активных и недавно отмененных желаний похожие на wish:
+// с той же ссылкой или с похожим названием. Самые похожие — первыми.
+func findDuplicates(wish Wish, list []Wish, now time.Time) []DuplicateMatch {
+	wishURL := normalizeWishURL(wish.URL)
+
+	var matches []DuplicateMatch
+	for _, w := range list {
+		if w.ID == wish.ID {
+			continue
+		}
+		switch w.Status {
+		case StatusActive:
+		case StatusCanceled:
+			if now.Sub(w.StatusChangedAt) > duplicateCanceledWindow {
+				continue
+			}
+		default:
+			continue
+		}
+
+		sim := titleSimilarity(wish.Title, w.Title)
+		switch {
+		case wishURL != "" && wishURL == normalizeWishURL(w.URL):
+			matches = append(matches, DuplicateMatch{Wish: w, Reason: "url", Similarity: sim})
+		case sim >= duplicateTitleThreshold:
+			matches = append(matches, DuplicateMatch{Wish: w, Reason: "title", Similarity: sim})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if (matches[i].Reason == "url") != (matches[j].Reason == "url") {
+			return matches[i].Reason == "url"
+		}
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	return matches
+}
+
+// mergeWishes переносит в target данные дублей: самую раннюю дату создания и начала
+// охлаждения, метки, заметки и ссылку, если у target их нет
+func mergeWishes(target *Wish, dups []Wish, now time.Time) {
+	start := func(w Wish) time.Time {
+		if !w.CoolingStartedAt.IsZero() {
+			return w.CoolingStartedAt
+		}
+		return w.CreatedAt
+	}
+
+	created, cooling := target.CreatedAt, start(*target)
+	ids := make([]string, 0, len(dups))
+	tags := append([]string(nil), target.Tags...)
+	for _, d := range dups {
+		ids = append(ids, d.ID)
+		if d.CreatedAt.Before(created) {
+			created = d.CreatedAt
+		}
+		if s := start(d); s.Before(cooling) {
+			cooling = s
+		}
+		tags = append(tags, d.Tags...)
+		if d.Notes != "" && !strings.Contains(target.Notes, d.Notes) {
+			if target.Notes != "" {
+				target.Notes += "\n\n"
+			}
+			target.Notes += d.Notes
+		}
+		if target.URL == "" {
+			target.URL = d.URL
+		}
+	}
+
+	changes := []FieldChange{{Field: "mergedFrom", To: strings.Join(ids, ",")}}
+	if !created.Equal(target.CreatedAt) {
+		changes = append(changes, FieldChange{
+			Field: "createdAt",
+			From:  target.CreatedAt.Format(time.RFC3339),
+			To:    created.Format(time.RFC3339),
+		})
+	}
+	target.CreatedAt = created
+	target.CoolingStartedAt = cooling
+	if cooling.Equal(created) {
+		target.CoolingStartedAt = time.Time{}
+	}
+	target.Tags = normalizeTags(tags)
+	target.Edits = append(target.Edits, WishEdit{At: now, Changes: changes})
+}
+
+// MergeWishHandler создает обработчик объединения дублей
+// @Summary Объединить дубли
+// @Description Переносит в желание данные дублей и удаляет их. Объединяются только активные желания; если меток или заметок получается больше допустимого, объединение отклоняется. Охлаждение считается от самой ранней даты создания.
+// @Tags wishes
+// @Param userId path string true "ID пользователя"
+// @Param wishId path string true "ID желания, которое останется"
+// @Param merge body MergeRequest true "Дубли"
+// @Accept json
+// @Produce json
+// @Success 200 {object} Wish
+// @Failure 400 {object} Problem "ошибка валидации"
+// @Failure 404 {object} Problem "not found"
+// @Failure 409 {object} Problem "only active wishes can be merged"
+// @Failure 422 {object} Problem "merged wish is invalid"
+// @Security SessionToken
+// @Router /wishes/{userId}/{wishId}/merge [post]
+func MergeWishHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userId, wishId := vars["userId"], vars["wishId"]
+
+		var body MergeRequest
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if len(body.DuplicateIDs) == 0 {
+			writeFieldErrors(w, r, []FieldError{{Field: "duplicateIds", Message: "is required"}})
+			return
+		}
+
+		wish, err := storage.MergeWishes(r.Context(), userId, wishId, body.DuplicateIDs)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "duplicates merged", "user_id", userId, "wish_id", wishId, "duplicate_ids", body.DuplicateIDs)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wish)
+	}
+}
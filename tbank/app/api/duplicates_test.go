@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMergeWishes(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Наушники", Price: 10000, Tags: []string{"звук"}, Notes: "черные"})
+	storage.AddWish(ctx, "u1", Wish{ID: "w2", Title: "Наушники Sony", Price: 10000, Tags: []string{"подарок"}, Notes: "в рассрочку"})
+
+	got, err := storage.MergeWishes(ctx, "u1", "w1", []string{"w2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tags) != 2 || got.Notes != "черные\n\nв рассрочку" {
+		t.Errorf("merged wish: tags %v, notes %q", got.Tags, got.Notes)
+	}
+	if n := len(storage.GetWishes("u1", StatusActive)); n != 1 {
+		t.Errorf("active wishes after merge = %d, want 1", n)
+	}
+}
+
+func TestMergeWishesRejectsInvalidResult(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	tags := func(prefix string, n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return out
+	}
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Наушники", Price: 10000, Tags: tags("a", maxTags)})
+	storage.AddWish(ctx, "u1", Wish{ID: "w2", Title: "Наушники", Price: 10000, Tags: tags("b", 1)})
+
+	_, err := storage.MergeWishes(ctx, "u1", "w1", []string{"w2"})
+	if !errors.Is(err, errMergeInvalid) || !errors.Is(err, errTooManyTags) {
+		t.Fatalf("error = %v, want errMergeInvalid with errTooManyTags", err)
+	}
+	if n := len(storage.GetWishes("u1", StatusActive)); n != 2 {
+		t.Errorf("active wishes after rejected merge = %d, want 2", n)
+	}
+
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest("POST", "/wishes/u1/w1/merge", nil), err)
+	if rec.Code != 422 {
+		t.Errorf("status = %d, want 422", rec.Code)
+	}
+}
+
+func TestMergeWishesOnlyActive(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Наушники", Price: 10000})
+	storage.AddWish(ctx, "u1", Wish{ID: "w2", Title: "Наушники", Price: 10000})
+	storage.AddWish(ctx, "u1", Wish{ID: "w3", Title: "Наушники", Price: 10000})
+	if _, err := storage.UpdateWishStatus(ctx, "u1", "w2", StatusCompleted); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := storage.MergeWishes(ctx, "u1", "w1", []string{"w2"}); !errors.Is(err, errMergeInactive) {
+		t.Errorf("merging a completed duplicate: error = %v, want errMergeInactive", err)
+	}
+	if _, err := storage.MergeWishes(ctx, "u1", "w2", []string{"w3"}); !errors.Is(err, errMergeInactive) {
+		t.Errorf("merging into a completed wish: error = %v, want errMergeInactive", err)
+	}
+	if n := len(storage.GetWishes("u1", StatusCompleted)); n != 1 {
+		t.Errorf("completed wishes = %d, want the completed one kept", n)
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// userExport — данные пользователя для выгрузки. Профиль и настройки берутся сразу,
+// а желания — по одному статусу в момент записи, так что в памяти держится
+// не вся история, а только список текущего статуса.
+type userExport struct {
+	Profile  UserProfile
+	Settings Settings
+	// Wishes возвращает снимок желаний со статусом status
+	Wishes func(status WishStatus) []Wish
+}
+
+// collectExport готовит выгрузку пользователя из хранилища
+func collectExport(storage *Storage, userId string) userExport {
+	profile, ok := storage.GetProfile(userId)
+	if !ok {
+		profile = UserProfile{Nick: storage.Nick(userId), BlockedCategories: []string{}}
+	}
+
+	return userExport{
+		Profile:  profile,
+		Settings: maskSettings(storage.GetSettings(userId)),
+		Wishes: func(status WishStatus) []Wish {
+			return storage.GetWishes(userId, status)
+		},
+	}
+}
+
+// wishExportHeader — заголовок таблицы желаний
+var wishExportHeader = []string{
+	"id", "title", "price", "currency", "convertedPrice", "baseCurrency", "category", "status", "stillWant",
+	"coolingDays", "recommendedCooling", "comfortMonths", "createdAt", "updateAt",
+	"notes", "url", "tags", "priority", "targetDate",
+}
+
+// wishExportRecord переводит желание в строку таблицы
+func wishExportRecord(w Wish) []string {
+	return []string{
+		w.ID,
+		w.Title,
+		formatFloat(w.Price),
+		w.Currency,
+		formatFloat(w.ConvertedPrice),
+		w.BaseCurrency,
+		w.Category,
+		string(w.Status),
+		strconv.FormatBool(w.StillWant),
+		strconv.Itoa(w.CoolingDays),
+		strconv.Itoa(w.RecommendedCooling),
+		strconv.Itoa(w.ComfortMonths),
+		formatTime(w.CreatedAt),
+		formatTime(w.UpdateAt),
+		w.Notes,
+		w.URL,
+		strings.Join(w.Tags, ";"),
+		string(w.Priority),
+		formatTime(w.TargetDate),
+	}
+}
+
+// profileExportRecords переводит профиль в пары ключ-значение
+func profileExportRecords(p UserProfile) [][]string {
+	return [][]string{
+		{"nick", p.Nick},
+		{"currency", profileCurrency(p)},
+		{"salary", formatFloat(p.Salary)},
+		{"totalSavingsProfile", formatFloat(p.TotalSavingsProfile)},
+		{"monthlySavingProfile", formatFloat(p.MonthlySavingProfile)},
+		{"comfortPercent", formatFloat(p.ComfortPercent)},
+		{"blockedCategories", strings.Join(p.BlockedCategories, ", ")},
+	}
+}
+
+// settingsExportRecords переводит настройки в пары ключ-значение.
+// Токены и пароли в выгрузку не попадают.
+func settingsExportRecords(s Settings) [][]string {
+	out := [][]string{
+		{"notificationFrequency", s.NotificationFreq},
+		{"notificationChannel", s.NotificationChannel},
+		{"excludedProducts", s.ExcludedProducts},
+		{"totalSpent", formatFloat(s.TotalSpent)},
+		{"totalPurchases", strconv.Itoa(s.TotalPurchases)},
+		{"monthlySaving", formatFloat(s.MonthlySaving)},
+		{"email", s.Email},
+		{"smtpEmail", s.SMTPEmail},
+		{"telegramChatId", s.TelegramChatID},
+	}
+	for i, c := range s.Cooldowns {
+		out = append(out, []string{
+			fmt.Sprintf("cooldowns[%d]", i),
+			fmt.Sprintf("%s-%s: %d", formatFloat(c.Min), cooldownMax(c), c.Period),
+		})
+	}
+	for i, c := range s.CategoryCooling {
+		out = append(out, []string{
+			fmt.Sprintf("categoryCooling[%d]", i),
+			fmt.Sprintf("%s: %d-%d", c.Category, c.MinDays, c.MaxDays),
+		})
+	}
+	return out
+}
+
+// cooldownMax форматирует верхнюю границу диапазона; у открытого диапазона — пусто
+func cooldownMax(c CooldownRange) string {
+	if c.Unbounded {
+		return ""
+	}
+	return formatFloat(c.Max)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// csvFormulaPrefixes — символы, с которых Excel и Google Таблицы начинают формулу
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafe защищает ячейки от выполнения как формул: текст, начинающийся с
+// csvFormulaPrefixes, получает префикс '. Числа остаются как есть.
+func csvSafe(record []string) []string {
+	out := make([]string, len(record))
+	for i, cell := range record {
+		out[i] = cell
+		if cell == "" || !strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			out[i] = "'" + cell
+		}
+	}
+	return out
+}
+
+// writeExportCSV пишет выгрузку в CSV: секции профиля и настроек, затем таблица желаний
+func writeExportCSV(out io.Writer, data userExport) error {
+	cw := csv.NewWriter(out)
+	write := func(records ...[]string) error {
+		for _, rec := range records {
+			if err := cw.Write(csvSafe(rec)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := write([]string{"# profile"}); err != nil {
+		return err
+	}
+	if err := write(profileExportRecords(data.Profile)...); err != nil {
+		return err
+	}
+	if err := write([]string{}, []string{"# settings"}); err != nil {
+		return err
+	}
+	if err := write(settingsExportRecords(data.Settings)...); err != nil {
+		return err
+	}
+	if err := write([]string{}, []string{"# wishes"}, wishExportHeader); err != nil {
+		return err
+	}
+	for _, status := range wishStatuses {
+		for _, w := range data.Wishes(status) {
+			if err := write(wishExportRecord(w)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeExportJSON пишет выгрузку в отформатированный JSON, по одному желанию за раз
+func writeExportJSON(out io.Writer, data userExport) error {
+	field := func(name string, v any, last bool) error {
+		b, err := json.MarshalIndent(v, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if last {
+			sep = "\n"
+		}
+		_, err = fmt.Fprintf(out, "  %q: %s%s", name, b, sep)
+		return err
+	}
+
+	if _, err := io.WriteString(out, "{\n"); err != nil {
+		return err
+	}
+	if err := field("profile", data.Profile, false); err != nil {
+		return err
+	}
+	if err := field("settings", data.Settings, false); err != nil {
+		return err
+	}
+
+	for i, status := range wishStatuses {
+		if _, err := fmt.Fprintf(out, "  %q: [", status); err != nil {
+			return err
+		}
+		wishes := data.Wishes(status)
+		for j, w := range wishes {
+			b, err := json.MarshalIndent(w, "    ", "  ")
+			if err != nil {
+				return err
+			}
+			sep := "\n    "
+			if j > 0 {
+				sep = ",\n    "
+			}
+			if _, err := fmt.Fprintf(out, "%s%s", sep, b); err != nil {
+				return err
+			}
+		}
+		closing := "]"
+		if len(wishes) > 0 {
+			closing = "\n  ]"
+		}
+		if i < len(wishStatuses)-1 {
+			closing += ","
+		}
+		if _, err := io.WriteString(out, closing+"\n"); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(out, "}\n")
+	return err
+}
+
+// writeExportXLSX пишет минимальную книгу XLSX: по листу на каждый статус, профиль и настройки.
+// Состав листов известен заранее, поэтому служебные части пишутся первыми,
+// а строки каждого листа — сразу в его запись архива, без промежуточной таблицы.
+func writeExportXLSX(out io.Writer, data userExport) error {
+	names := make([]string, 0, len(wishStatuses)+2)
+	for _, status := range wishStatuses {
+		names = append(names, string(status))
+	}
+	names = append(names, "profile", "settings")
+
+	zw := zip.NewWriter(out)
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(xml.Header)
+	types.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	types.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	types.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	types.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+
+	rels.WriteString(xml.Header)
+	rels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, name := range names {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+
+	sheet := 0
+	writeSheet := func(fill func(sw *xlsxSheetWriter)) error {
+		sheet++
+		f, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", sheet))
+		if err != nil {
+			return err
+		}
+		sw := newXLSXSheetWriter(f)
+		fill(sw)
+		return sw.Close()
+	}
+	for _, status := range wishStatuses {
+		err := writeSheet(func(sw *xlsxSheetWriter) {
+			sw.WriteRow(wishExportHeader)
+			for _, w := range data.Wishes(status) {
+				sw.WriteRow(wishExportRecord(w))
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, records := range [][][]string{profileExportRecords(data.Profile), settingsExportRecords(data.Settings)} {
+		err := writeSheet(func(sw *xlsxSheetWriter) {
+			for _, row := range records {
+				sw.WriteRow(row)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// xlsxSheetWriter пишет лист построчно; ячейки хранятся как inline-строки.
+// Первая ошибка записи запоминается и возвращается из Close.
+type xlsxSheetWriter struct {
+	out io.Writer
+	row int
+	err error
+}
+
+func newXLSXSheetWriter(out io.Writer) *xlsxSheetWriter {
+	sw := &xlsxSheetWriter{out: out}
+	sw.write(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return sw
+}
+
+func (sw *xlsxSheetWriter) write(s string) {
+	if sw.err == nil {
+		_, sw.err = io.WriteString(sw.out, s)
+	}
+}
+
+// WriteRow дописывает строку листа
+func (sw *xlsxSheetWriter) WriteRow(cells []string) {
+	sw.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, sw.row)
+	for j, cell := range cells {
+		fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, xlsxColumn(j), sw.row, xmlEscape(cell))
+	}
+	b.WriteString(`</row>`)
+	sw.write(b.String())
+}
+
+// Close закрывает разметку листа
+func (sw *xlsxSheetWriter) Close() error {
+	sw.write(`</sheetData></worksheet>`)
+	return sw.err
+}
+
+// xlsxColumn возвращает буквенное имя колонки: 0 -> A, 26 -> AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// ExportHandler создает обработчик для выгрузки истории желаний
+// @Summary Выгрузить историю желаний
+// @Description Выгружает активные, выполненные и отмененные желания вместе с профилем и настройками в CSV, JSON или XLSX
+// @Tags export
+// @Param userId path string true "ID пользователя"
+// @Param format query string false "Формат выгрузки: csv, json, xlsx" default(json)
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Success 200 {file} file
+// @Failure 400 {object} Problem "validation_failed"
+// @Security SessionToken
+// @Router /export/{userId} [get]
+func ExportHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+
+		var write func(io.Writer, userExport) error
+		var contentType string
+		switch format {
+		case "csv":
+			write, contentType = writeExportCSV, "text/csv; charset=utf-8"
+		case "json":
+			write, contentType = writeExportJSON, "application/json"
+		case "xlsx":
+			write, contentType = writeExportXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		default:
+			writeFieldErrors(w, r, []FieldError{{Field: "format", Message: "must be csv, json or xlsx"}})
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "wishes exported", "user_id", userId, "format", format)
+		data := collectExport(storage, userId)
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": "twish-" + userId + "." + format,
+		}))
+		if err := write(w, data); err != nil {
+			logger("handler").ErrorContext(r.Context(), "export failed", "user_id", userId, "format", format, "error", err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func testExport() userExport {
+	return userExport{
+		Profile: UserProfile{Nick: "cat", Currency: "RUB"},
+		Settings: maskSettings(Settings{
+			Cooldowns:     []CooldownRange{{Min: 0, Max: 1000, Period: 1}, {Min: 1000, Period: 7, Unbounded: true}},
+			Email:         "cat@example.com",
+			TelegramToken: "123456:secret",
+			SMTPPassword:  "hunter2",
+		}),
+		Wishes: exportWishes(map[WishStatus][]Wish{
+			StatusActive:    {{ID: "w1", Title: `Кофемашина <"A&B">`, Price: 12990.5, Status: StatusActive, Tags: []string{"кухня", "дом"}}},
+			StatusCompleted: {{ID: "w2", Title: "Книга", Price: 500, Status: StatusCompleted}},
+		}),
+	}
+}
+
+func exportWishes(m map[WishStatus][]Wish) func(WishStatus) []Wish {
+	return func(status WishStatus) []Wish { return m[status] }
+}
+
+func TestWriteExportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExportCSV(&buf, testExport()); err != nil {
+		t.Fatal(err)
+	}
+	r := csv.NewReader(&buf)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+
+	var wishes [][]string
+	for i, rec := range records {
+		if rec[0] == "id" {
+			wishes = records[i+1:]
+			break
+		}
+	}
+	if len(wishes) != 2 || wishes[0][0] != "w1" || wishes[1][0] != "w2" {
+		t.Fatalf("wish rows = %v", wishes)
+	}
+	if got := wishes[0][1]; got != `Кофемашина <"A&B">` {
+		t.Errorf("title = %q", got)
+	}
+	if got := wishes[0][16]; got != "кухня;дом" {
+		t.Errorf("tags = %q", got)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "123456:secret") {
+		t.Error("CSV export contains credentials")
+	}
+}
+
+func TestWriteExportCSVEscapesFormulas(t *testing.T) {
+	data := testExport()
+	data.Wishes = exportWishes(map[WishStatus][]Wish{
+		StatusActive: {{ID: "w1", Title: "=HYPERLINK(\"http://evil\")", Notes: "@SUM(A1)", Category: "-2+3", Price: 10}},
+	})
+	var buf bytes.Buffer
+	if err := writeExportCSV(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	r := csv.NewReader(&buf)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := records[len(records)-1]
+	if row[1] != "'=HYPERLINK(\"http://evil\")" || row[14] != "'@SUM(A1)" || row[6] != "'-2+3" {
+		t.Errorf("formula cells are not escaped: %q", row)
+	}
+	if row[2] != "10" {
+		t.Errorf("price = %q", row[2])
+	}
+	if got := csvSafe([]string{"-5", "\tx", "ok"}); got[0] != "-5" || got[1] != "'\tx" || got[2] != "ok" {
+		t.Errorf("csvSafe = %q", got)
+	}
+}
+
+func TestWriteExportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExportJSON(&buf, testExport()); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Profile  UserProfile
+		Settings Settings
+		Active   []Wish `json:"active"`
+		Done     []Wish `json:"completed"`
+		Canceled []Wish `json:"canceled"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(got.Active) != 1 || len(got.Done) != 1 || got.Canceled == nil || len(got.Canceled) != 0 {
+		t.Errorf("wishes by status = %d/%d/%v", len(got.Active), len(got.Done), got.Canceled)
+	}
+	if got.Settings.TelegramToken != secretMask || got.Settings.SMTPPassword != secretMask {
+		t.Errorf("credentials are not masked: %q, %q", got.Settings.TelegramToken, got.Settings.SMTPPassword)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Error("JSON export contains the SMTP password")
+	}
+}
+
+func TestWriteExportXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExportXLSX(&buf, testExport()); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	for i := 1; i <= len(wishStatuses)+2; i++ {
+		if _, ok := parts[fmt.Sprintf("xl/worksheets/sheet%d.xml", i)]; !ok {
+			t.Errorf("missing sheet %d", i)
+		}
+	}
+	active := parts["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(active, `<row r="2">`) || !strings.Contains(active, "Кофемашина &lt;&#34;A&amp;B&#34;&gt;") {
+		t.Errorf("active sheet does not contain the escaped wish row:\n%s", active)
+	}
+	if !strings.HasSuffix(active, "</sheetData></worksheet>") {
+		t.Error("active sheet is not closed")
+	}
+	if strings.Contains(parts["xl/worksheets/sheet5.xml"], "hunter2") {
+		t.Error("settings sheet contains the SMTP password")
+	}
+}
+
+func TestExportHandlerContentDisposition(t *testing.T) {
+	storage := NewStorage()
+	req := httptest.NewRequest("GET", "/export/x?format=csv", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": `a"; filename=evil.exe`})
+	rec := httptest.NewRecorder()
+	ExportHandler(storage)(rec, req)
+
+	_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+	if err != nil {
+		t.Fatalf("Content-Disposition %q: %v", rec.Header().Get("Content-Disposition"), err)
+	}
+	if want := `twish-a"; filename=evil.exe.csv`; params["filename"] != want {
+		t.Errorf("filename = %q, want %q", params["filename"], want)
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -17,14 +20,146 @@ func generateUID() string {
 	return strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.Itoa(rand.Intn(1000))
 }
 
-// calcRecommendedCooling рассчитывает рекомендуемый период охлаждения
-func calcRecommendedCooling(price float64, settings Settings) int {
-	for _, r := range settings.Cooldowns {
-		if price >= r.Min && price <= r.Max {
-			return r.Period
+var (
+	errEmptyTitle      = errors.New("title is required")
+	errLongTitle       = errors.New("title is too long")
+	errInvalidPrice    = errors.New("price must be positive")
+	errLongCategory    = errors.New("category is too long")
+	errBlockedCategory = errors.New("category is blocked in profile")
+	errTooManyTags     = errors.New("too many tags")
+	errLongTag         = errors.New("tag is too long")
+	errInvalidPriority = errors.New("priority must be low, normal or high")
+	errInvalidTarget   = errors.New("targetDate must be YYYY-MM-DD or RFC 3339")
+)
+
+const (
+	// maxTitleLen — максимальная длина названия желания
+	maxTitleLen = 200
+	// maxCategoryLen — максимальная длина категории
+	maxCategoryLen = 100
+	// maxTags — максимальное количество меток у желания
+	maxTags = 10
+	// maxTagLen — максимальная длина метки
+	maxTagLen = 30
+)
+
+// isCategoryBlocked проверяет, запрещена ли категория в профиле (без учета регистра)
+func isCategoryBlocked(profile UserProfile, category string) bool {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if category == "" {
+		return false
+	}
+	for _, b := range profile.BlockedCategories {
+		if strings.ToLower(strings.TrimSpace(b)) == category {
+			return true
 		}
 	}
-	return 7 // дефолт
+	return false
+}
+
+// normalizeTags приводит метки к нижнему регистру, убирает пустые и повторы
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// parseTargetDate разбирает желаемую дату покупки; пустая строка — без даты
+func parseTargetDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := parseQueryTime(raw)
+	if err != nil {
+		return time.Time{}, errInvalidTarget
+	}
+	return t, nil
+}
+
+// validateWish проверяет поля желания
+func validateWish(w Wish, profile UserProfile) error {
+	if w.Title == "" {
+		return errEmptyTitle
+	}
+	if len([]rune(w.Title)) > maxTitleLen {
+		return errLongTitle
+	}
+	if w.Price <= 0 || math.IsNaN(w.Price) || math.IsInf(w.Price, 0) {
+		return errInvalidPrice
+	}
+	if len([]rune(w.Category)) > maxCategoryLen {
+		return errLongCategory
+	}
+	if isCategoryBlocked(profile, w.Category) {
+		return errBlockedCategory
+	}
+	if len([]rune(w.Notes)) > maxNotesLen {
+		return errLongNotes
+	}
+	if err := validateWishURL(w.URL); err != nil {
+		return err
+	}
+	if len(w.Tags) > maxTags {
+		return errTooManyTags
+	}
+	for _, t := range w.Tags {
+		if len([]rune(t)) > maxTagLen {
+			return errLongTag
+		}
+	}
+	if w.Priority != "" && !w.Priority.Valid() {
+		return errInvalidPriority
+	}
+	return nil
+}
+
+// newWish проверяет поля и собирает новое желание с расчетом охлаждения и комфорта
+// по цене, пересчитанной в валюту профиля
+func newWish(in WishInput, settings Settings, profile UserProfile, rates RateProvider) (Wish, error) {
+	target, err := parseTargetDate(in.TargetDate)
+	if err != nil {
+		return Wish{}, err
+	}
+	if in.Priority == "" {
+		in.Priority = PriorityNormal
+	}
+
+	now := time.Now()
+	wish := Wish{
+		ID:          generateUID(),
+		Title:       strings.TrimSpace(in.Title),
+		Price:       in.Price,
+		Currency:    in.Currency,
+		Category:    strings.TrimSpace(in.Category),
+		CoolingDays: 0,
+		StillWant:   true,
+		CreatedAt:   now,
+		UpdateAt:    now,
+		Status:      StatusActive,
+		Notes:       strings.TrimSpace(in.Notes),
+		URL:         strings.TrimSpace(in.URL),
+		Tags:        normalizeTags(in.Tags),
+		Priority:    in.Priority,
+		TargetDate:  target,
+	}
+	if err := validateWish(wish, profile); err != nil {
+		return Wish{}, err
+	}
+	if err := convertWish(&wish, profileCurrency(profile), rates); err != nil {
+		return Wish{}, err
+	}
+	wish.RecommendedCooling = calcRecommendedCooling(wish.ConvertedPrice, wish.Category, settings)
+	wish.ComfortMonths = CalculateComfortMonths(profile, wish.ConvertedPrice)
+	return wish, nil
 }
 
 // CalculateComfortMonths рассчитывает количество месяцев комфорта для желания
@@ -50,89 +185,408 @@ func CalculateComfortMonths(profile UserProfile, price float64) int {
 	return int(math.Ceil(months))
 }
 
+// parseQueryTime разбирает дату в формате RFC 3339 или 2006-01-02
+func parseQueryTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// parseWishQuery собирает WishQuery из параметров запроса
+func parseWishQuery(r *http.Request) (WishQuery, error) {
+	v := r.URL.Query()
+	q := WishQuery{
+		Status:   WishStatus(v.Get("status")),
+		Text:     v.Get("q"),
+		Category: v.Get("category"),
+		Tag:      v.Get("tag"),
+		Sort:     v.Get("sort"),
+		Cursor:   v.Get("cursor"),
+	}
+
+	if q.Status != "" && !q.Status.Valid() {
+		return q, errors.New("unknown status")
+	}
+	if q.Sort != "" && !wishSortFields[q.Sort] {
+		return q, errors.New("unknown sort field")
+	}
+	switch v.Get("order") {
+	case "":
+		// по умолчанию новые желания идут первыми
+		q.Desc = q.Sort == ""
+	case "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+
+	for name, dst := range map[string]**float64{"minPrice": &q.MinPrice, "maxPrice": &q.MaxPrice} {
+		if raw := v.Get(name); raw != "" {
+			f, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = &f
+		}
+	}
+	for name, dst := range map[string]*time.Time{"createdFrom": &q.CreatedFrom, "createdTo": &q.CreatedTo} {
+		if raw := v.Get(name); raw != "" {
+			t, err := parseQueryTime(raw)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = t
+		}
+	}
+	if raw := v.Get("createdTo"); len(raw) == len("2006-01-02") {
+		// дата без времени включает весь день
+		q.CreatedTo = q.CreatedTo.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	if raw := v.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxQueryLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
 // GetWishesHandler возвращает обработчик для получения желаний пользователя
 // @Summary Получить список желаний пользователя
-// @Description Возвращает все желания пользователя по его ID
+// @Description Возвращает желания пользователя с поиском, фильтрами, сортировкой и курсорной пагинацией. Общее количество — в заголовке X-Total-Count, курсор следующей страницы — в X-Next-Cursor.
 // @Tags wishes
 // @Param userId path string true "ID пользователя"
+// @Param status query string false "Статус" Enums(active, completed, canceled)
+// @Param q query string false "Поиск по названию"
+// @Param category query string false "Категория"
+// @Param tag query string false "Метка"
+// @Param minPrice query number false "Минимальная цена"
+// @Param maxPrice query number false "Максимальная цена"
+// @Param createdFrom query string false "Создано не раньше (RFC 3339 или YYYY-MM-DD)"
+// @Param createdTo query string false "Создано не позже (RFC 3339 или YYYY-MM-DD)"
+// @Param sort query string false "Поле сортировки" Enums(created, price, comfort, priority)
+// @Param order query string false "Порядок" Enums(asc, desc)
+// @Param limit query int false "Размер страницы (до 100)"
+// @Param cursor query string false "Курсор следующей страницы"
 // @Produce json
 // @Success 200 {array} Wish
-// @Router /users/{userId}/wishes [get]
+// @Header 200 {integer} X-Total-Count "Всего подходящих желаний"
+// @Header 200 {string} X-Next-Cursor "Курсор следующей страницы"
+// @Failure 400 {object} Problem "invalid query"
+// @Security SessionToken
+// @Router /wishes/{userId} [get]
 func GetWishesHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userId := mux.Vars(r)["userId"]
-		status := r.URL.Query().Get("status") // optional: active/completed/cancelled
-		log.Printf("[Handler] GET wishes for %s (status=%s)\n", userId, status)
-		out := storage.GetWishes(userId, status)
+		q, err := parseWishQuery(r)
+		if err != nil {
+			writeError(w, r, badRequest(err))
+			return
+		}
+		logger("handler").DebugContext(r.Context(), "wishes listed", "user_id", userId, "status", q.Status)
+
+		page, err := storage.QueryWishes(userId, q)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(out)
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+		if page.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", page.NextCursor)
+		}
+		json.NewEncoder(w).Encode(page.Items)
 	}
 }
 
 // AddWishHandler создает обработчик для добавления нового желания
 // @Summary Добавить желание
-// @Description Создает новое желание для пользователя
+// @Description Создает новое желание для пользователя. Если среди активных и недавно отмененных
+// @Description есть желания с той же ссылкой или похожим названием, они возвращаются в duplicates.
 // @Tags wishes
 // @Param userId path string true "ID пользователя"
+// @Param wish body WishInput true "Новое желание"
+// @Accept json
 // @Produce json
-// @Success 200 {object} Wish
-// @Router /users/{userId}/wishes [post]
+// @Success 200 {object} AddWishResult
+// @Failure 400 {object} Problem "ошибка валидации"
+// @Security SessionToken
+// @Router /wishes/{userId} [post]
 func AddWishHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userId := mux.Vars(r)["userId"]
 
-		var body struct {
-			Title    string  `json:"title"`
-			Price    float64 `json:"price"`
-			Category string  `json:"category"`
+		var body WishInput
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+
+		settings := storage.GetSettings(userId)
+		profile, _ := storage.GetProfile(userId)
+
+		wish, err := newWish(body, settings, profile, storage.Rates())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		dups := storage.FindDuplicates(userId, wish)
+		storage.AddWish(r.Context(), userId, wish)
+		if len(dups) > 0 {
+			logger("handler").InfoContext(r.Context(), "possible duplicates found", "user_id", userId, "wish_id", wish.ID, "count", len(dups))
+		}
+		json.NewEncoder(w).Encode(AddWishResult{Wish: wish, Duplicates: dups})
+	}
+}
+
+var (
+	errLongNotes  = errors.New("notes are too long")
+	errInvalidURL = errors.New("url must be an absolute http(s) link")
+)
+
+// maxNotesLen — максимальная длина заметки к желанию
+const maxNotesLen = 2000
+
+// validateWishURL проверяет ссылку на товар; пустая ссылка допустима
+func validateWishURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errInvalidURL
+	}
+	return nil
+}
+
+// WishPatch — изменяемые поля желания; отсутствующие поля не трогаются
+// @Description Частичное изменение желания.
+type WishPatch struct {
+	Title    *string       `json:"title" validate:"required,maxlen=200"`
+	Price    *float64      `json:"price" validate:"gt=0"`
+	Currency *string       `json:"currency" validate:"maxlen=3"`
+	Category *string       `json:"category" validate:"maxlen=100"`
+	Notes    *string       `json:"notes" validate:"maxlen=2000"`
+	URL      *string       `json:"url" validate:"url,maxlen=2048"`
+	Tags     *[]string     `json:"tags" validate:"maxlen=10,itemmaxlen=30"`
+	Priority *WishPriority `json:"priority" enums:"low,normal,high" validate:"oneof=low normal high"`
+	// TargetDate — YYYY-MM-DD или RFC 3339; пустая строка снимает дату
+	TargetDate *string `json:"targetDate" validate:"maxlen=35"`
+}
+
+// applyWishPatch применяет изменения, пересчитывает охлаждение и комфорт и пишет запись в историю.
+// Охлаждение запускается заново, если цена выросла настолько, что рекомендованный срок стал длиннее.
+func applyWishPatch(w *Wish, patch WishPatch, settings Settings, profile UserProfile, rates RateProvider) error {
+	next := *w
+	var changes []FieldChange
+	setString := func(field string, dst *string, v *string) {
+		if v == nil {
+			return
+		}
+		nv := strings.TrimSpace(*v)
+		if nv != *dst {
+			changes = append(changes, FieldChange{Field: field, From: *dst, To: nv})
+			*dst = nv
+		}
+	}
+
+	if patch.Currency != nil {
+		c := normalizeCurrency(*patch.Currency)
+		patch.Currency = &c
+	}
+	setString("title", &next.Title, patch.Title)
+	setString("currency", &next.Currency, patch.Currency)
+	setString("category", &next.Category, patch.Category)
+	setString("notes", &next.Notes, patch.Notes)
+	setString("url", &next.URL, patch.URL)
+	if patch.Price != nil && *patch.Price != next.Price {
+		changes = append(changes, FieldChange{Field: "price", From: formatFloat(next.Price), To: formatFloat(*patch.Price)})
+		next.Price = *patch.Price
+	}
+	if patch.Tags != nil {
+		tags := normalizeTags(*patch.Tags)
+		if from, to := strings.Join(next.Tags, ", "), strings.Join(tags, ", "); from != to {
+			changes = append(changes, FieldChange{Field: "tags", From: from, To: to})
+			next.Tags = tags
+		}
+	}
+	if patch.Priority != nil && !patch.Priority.Valid() {
+		return errInvalidPriority
+	}
+	if patch.Priority != nil && *patch.Priority != next.Priority {
+		changes = append(changes, FieldChange{Field: "priority", From: string(next.Priority), To: string(*patch.Priority)})
+		next.Priority = *patch.Priority
+	}
+	if patch.TargetDate != nil {
+		target, err := parseTargetDate(*patch.TargetDate)
+		if err != nil {
+			return err
+		}
+		if !target.Equal(next.TargetDate) {
+			changes = append(changes, FieldChange{Field: "targetDate", From: formatTime(next.TargetDate), To: formatTime(target)})
+			next.TargetDate = target
+		}
+	}
+
+	if err := validateWish(next, profile); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := convertWish(&next, profileCurrency(profile), rates); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	restart := recalcWish(&next, *w, settings, profile, now)
+	next.Edits = append(next.Edits, WishEdit{At: now, Changes: changes, CoolingRestarted: restart})
+	*w = next
+	return nil
+}
+
+// recalcWish пересчитывает охлаждение и комфорт next после изменения prev.
+// Если цена выросла настолько, что рекомендованный срок стал длиннее, охлаждение
+// запускается заново с момента now; в этом случае возвращает true.
+func recalcWish(next *Wish, prev Wish, settings Settings, profile UserProfile, now time.Time) bool {
+	next.RecommendedCooling = calcRecommendedCooling(next.ConvertedPrice, next.Category, settings)
+	next.ComfortMonths = CalculateComfortMonths(profile, next.ConvertedPrice)
+
+	restart := next.BasePrice() > prev.BasePrice() && next.RecommendedCooling > prev.RecommendedCooling
+	if restart {
+		next.CoolingStartedAt = now
+	}
+	return restart
+}
+
+// EditWishHandler создает обработчик для редактирования желания
+// @Summary Изменить желание
+// @Description Меняет название, цену, категорию, заметки и ссылку; пересчитывает охлаждение и комфорт и пишет историю изменений
+// @Tags wishes
+// @Accept json
+// @Produce json
+// @Param userId path string true "ID пользователя"
+// @Param wishId path string true "ID желания"
+// @Param patch body WishPatch true "Изменяемые поля"
+// @Success 200 {object} Wish
+// @Failure 400 {object} Problem "ошибка валидации"
+// @Failure 404 {object} Problem "not found"
+// @Security SessionToken
+// @Router /wishes/{userId}/{wishId} [patch]
+func EditWishHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userId := vars["userId"]
+		wishId := vars["wishId"]
+
+		var patch WishPatch
+		if !decodeJSON(w, r, &patch) {
+			return
 		}
-		json.NewDecoder(r.Body).Decode(&body)
 
 		settings := storage.GetSettings(userId)
+		profile, _ := storage.GetProfile(userId)
+		rates := storage.Rates()
 
-		wish := Wish{
-			ID:                 generateUID(),
-			Title:              body.Title,
-			Price:              body.Price,
-			Category:           body.Category,
-			CoolingDays:        0,
-			RecommendedCooling: calcRecommendedCooling(body.Price, settings),
-			StillWant:          true,
-			CreatedAt:          time.Now(),
-			Status:             "active",
+		wish, err := storage.EditWish(r.Context(), userId, wishId, func(wish *Wish) error {
+			return applyWishPatch(wish, patch, settings, profile, rates)
+		})
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
 
-		storage.AddWish(userId, wish)
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(wish)
 	}
 }
 
-// ToggleWishHandler создает обработчик для переключения статуса желания
-// @Summary Переключить статус желания
-// @Description Инвертирует поле StillWant желания по его ID
+// writeStatusResult отвечает на смену статуса: обновленное желание, 404 или 409
+func writeStatusResult(w http.ResponseWriter, r *http.Request, wish Wish, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wish)
+}
+
+// ToggleWishHandler создает обработчик для смены статуса желания по действию
+// @Summary Сменить статус желания по действию
+// @Description Выполняет действие над желанием: complete, cancel или reopen
 // @Tags wishes
 // @Param userId path string true "ID пользователя"
 // @Param wishId path string true "ID желания"
-// @Success 200 {string} string "успешно обновлено"
-// @Router /users/{userId}/wishes/{wishId}/toggle [post]
+// @Param action query string true "Действие" Enums(complete, cancel, reopen)
+// @Produce json
+// @Success 200 {object} Wish
+// @Failure 400 {object} Problem "unknown action"
+// @Failure 404 {object} Problem "not found"
+// @Failure 409 {object} Problem "illegal status transition"
+// @Security SessionToken
+// @Router /wishes/{userId}/{wishId} [put]
 func ToggleWishHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		userId := vars["userId"]
 		wishId := vars["wishId"]
-		action := r.URL.Query().Get("action") // "complete" or "cancel"
-		target := "active"
-		if action == "complete" {
-			target = "completed"
-		} else if action == "cancel" {
-			target = "canceled"
-		}
-		ok := storage.UpdateWishStatus(userId, wishId, target)
+		action := r.URL.Query().Get("action")
+		target, ok := wishActions[action]
 		if !ok {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeFieldErrors(w, r, []FieldError{{Field: "action", Message: "must be complete, cancel or reopen"}})
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		wish, err := storage.UpdateWishStatus(r.Context(), userId, wishId, target)
+		writeStatusResult(w, r, wish, err)
+	}
+}
+
+// StatusChange — тело запроса смены статуса
+// @Description Новый статус желания.
+type StatusChange struct {
+	// Status — целевой статус
+	// example: "completed"
+	Status WishStatus `json:"status" enums:"active,completed,canceled" validate:"required,oneof=active completed canceled"`
+}
+
+// SetWishStatusHandler создает обработчик для смены статуса желания
+// @Summary Сменить статус желания
+// @Description Переводит желание в указанный статус, если переход разрешен
+// @Tags wishes
+// @Accept json
+// @Produce json
+// @Param userId path string true "ID пользователя"
+// @Param wishId path string true "ID желания"
+// @Param status body StatusChange true "Новый статус"
+// @Success 200 {object} Wish
+// @Failure 400 {object} Problem "unknown status"
+// @Failure 404 {object} Problem "not found"
+// @Failure 409 {object} Problem "illegal status transition"
+// @Security SessionToken
+// @Router /wishes/{userId}/{wishId}/status [put]
+func SetWishStatusHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userId := vars["userId"]
+		wishId := vars["wishId"]
+
+		var body StatusChange
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if !body.Status.Valid() {
+			writeFieldErrors(w, r, []FieldError{{Field: "status", Message: "must be active, completed or canceled"}})
+			return
+		}
+
+		wish, err := storage.UpdateWishStatus(r.Context(), userId, wishId, body.Status)
+		writeStatusResult(w, r, wish, err)
 	}
 }
 
@@ -142,16 +596,18 @@ func ToggleWishHandler(storage *Storage) http.HandlerFunc {
 // @Tags wishes
 // @Param userId path string true "ID пользователя"
 // @Param wishId path string true "ID желания"
-// @Success 200 {string} string "успешно удалено"
-// @Router /users/{userId}/wishes/{wishId} [delete]
+// @Success 204 "удалено"
+// @Failure 404 {object} Problem "wish_not_found"
+// @Security SessionToken
+// @Router /wishes/{userId}/{wishId} [delete]
 func RemoveWishHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		userId := vars["userId"]
 		wishId := vars["wishId"]
-		ok := storage.RemoveWish(userId, wishId)
+		ok := storage.RemoveWish(r.Context(), userId, wishId)
 		if !ok {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeError(w, r, errWishNotFound)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -160,39 +616,59 @@ func RemoveWishHandler(storage *Storage) http.HandlerFunc {
 
 // GetSettingsHandler создает обработчик для получения настроек пользователя
 // @Summary Получить настройки пользователя
-// @Description Возвращает настройки по ID пользователя
+// @Description Возвращает настройки по ID пользователя; токены и пароли заменены маской
 // @Tags settings
 // @Param userId path string true "ID пользователя"
 // @Produce json
 // @Success 200 {object} Settings
-// @Router /users/{userId}/settings [get]
+// @Security SessionToken
+// @Router /settings/{userId} [get]
 func GetSettingsHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userId := mux.Vars(r)["userId"]
-		log.Printf("[Handler] GET settings for %s\n", userId)
+		logger("handler").DebugContext(r.Context(), "settings read", "user_id", userId)
 		set := storage.GetSettings(userId)
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(set)
+		json.NewEncoder(w).Encode(maskSettings(set))
 	}
 }
 
+// validateSettings проверяет диапазоны охлаждения и правила категорий
+func validateSettings(set Settings) []FieldError {
+	return append(validateCooldowns(set.Cooldowns), validateCategoryCooling(set.CategoryCooling)...)
+}
+
+// validateProfile проверяет, что для валюты профиля известен курс
+func validateProfile(p UserProfile, rates RateProvider) []FieldError {
+	c := profileCurrency(p)
+	if _, err := rates.Rate(c, c); err != nil {
+		return []FieldError{{Field: "currency", Message: err.Error()}}
+	}
+	return nil
+}
+
 // SaveSettingsHandler создает обработчик для сохранения настроек пользователя
 // @Summary Сохранить настройки пользователя
-// @Description Обновляет настройки по ID пользователя
+// @Description Обновляет настройки по ID пользователя и пересчитывает охлаждение активных желаний
 // @Tags settings
 // @Param userId path string true "ID пользователя"
 // @Param settings body Settings true "Объект настроек"
-// @Success 200 {string} string "успешно сохранено"
-// @Router /users/{userId}/settings [post]
+// @Success 200 "сохранено, тело пустое"
+// @Failure 400 {object} Problem "validation_failed"
+// @Security SessionToken
+// @Router /settings/{userId} [post]
 func SaveSettingsHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userId := mux.Vars(r)["userId"]
 		var set Settings
-		if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
+		if !decodeJSON(w, r, &set) {
 			return
 		}
-		storage.SaveSettings(userId, set)
+		if errs := validateSettings(set); len(errs) > 0 {
+			writeFieldErrors(w, r, errs)
+			return
+		}
+		storage.SaveSettings(r.Context(), userId, set)
 		w.WriteHeader(http.StatusOK)
 	}
 }
@@ -204,11 +680,12 @@ func SaveSettingsHandler(storage *Storage) http.HandlerFunc {
 // @Param nick path string true "Ник пользователя"
 // @Produce json
 // @Success 200 {object} UserProfile
-// @Router /users/{nick}/profile [get]
+// @Security SessionToken
+// @Router /user/{nick} [get]
 func GetProfileHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		nick := mux.Vars(r)["nick"]
-		log.Printf("[Handler] GET profile for %s\n", nick)
+		logger("handler").DebugContext(r.Context(), "profile read", "user_id", nick)
 		if p, ok := storage.GetProfile(nick); ok {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(p)
@@ -216,7 +693,7 @@ func GetProfileHandler(storage *Storage) http.HandlerFunc {
 		}
 		// return
 		def := UserProfile{
-			Nick:                 nick,
+			Nick:                 storage.Nick(nick),
 			Salary:               0,
 			TotalSavingsProfile:  0,
 			MonthlySavingProfile: 0,
@@ -233,19 +710,22 @@ func GetProfileHandler(storage *Storage) http.HandlerFunc {
 // @Tags profile
 // @Param nick path string true "Ник пользователя"
 // @Param profile body UserProfile true "Объект профиля"
-// @Success 200 {string} string "успешно сохранено"
-// @Router /users/{nick}/profile [post]
+// @Success 200 "сохранено, тело пустое"
+// @Failure 400 {object} Problem "validation_failed"
+// @Security SessionToken
+// @Router /user/{nick} [post]
 func SaveProfileHandler(storage *Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		nick := mux.Vars(r)["nick"]
 		var p UserProfile
-		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-			http.Error(w, "invalid json", http.StatusBadRequest)
+		if !decodeJSON(w, r, &p) {
+			return
+		}
+		if errs := validateProfile(p, storage.Rates()); len(errs) > 0 {
+			writeFieldErrors(w, r, errs)
 			return
 		}
-		p.Nick = nick
-		storage.SaveProfile(nick, p)
+		storage.SaveProfile(r.Context(), nick, p)
 		w.WriteHeader(http.StatusOK)
-		log.Printf("[Handler] Saved profile for %s: %+v\n", nick, p)
 	}
 }
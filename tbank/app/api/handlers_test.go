@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyWishPatchRejectsEmptyPriority(t *testing.T) {
+	w := Wish{Title: "Книга", Price: 500, Priority: PriorityHigh}
+	empty := WishPriority("")
+	err := applyWishPatch(&w, WishPatch{Priority: &empty}, Settings{}, UserProfile{}, nil)
+	if !errors.Is(err, errInvalidPriority) {
+		t.Fatalf("applyWishPatch() error = %v, want %v", err, errInvalidPriority)
+	}
+	if w.Priority != PriorityHigh {
+		t.Errorf("priority = %q, want it unchanged", w.Priority)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// readinessTimeout — сколько ждать каждую проверку готовности
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck — проверка, без которой сервер не должен получать трафик
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Readiness — ответ /readyz: общий статус и результат каждой проверки
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// registerOpsRoutes добавляет служебные эндпоинты для балансировщика и мониторинга.
+// Они живут вне apiPrefix, не требуют сессии и не входят в спецификацию API.
+func registerOpsRoutes(r *mux.Router, checks ...ReadinessCheck) {
+	r.HandleFunc("/healthz", HealthzHandler()).Methods("GET")
+	r.HandleFunc("/readyz", ReadyzHandler(checks)).Methods("GET")
+	r.HandleFunc("/metrics", MetricsHandler()).Methods("GET")
+}
+
+// HealthzHandler отвечает 200, пока процесс жив и обрабатывает запросы
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}
+}
+
+// ReadyzHandler выполняет проверки готовности и отвечает 200, если все прошли, иначе 503
+func ReadyzHandler(checks []ReadinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := Readiness{Status: "ok", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, c := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			err := c.Check(ctx)
+			cancel()
+			if err != nil {
+				resp.Checks[c.Name] = err.Error()
+				resp.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[c.Name] = "ok"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxConfigVersions — сколько версий настроек и профиля хранится на пользователя
+const maxConfigVersions = 50
+
+// secretMask — замена секретов в истории
+const secretMask = "***"
+
+// errVersionNotFound — версии с таким номером нет в истории
+var errVersionNotFound = errors.New("version not found")
+
+// secretFields — поля, значения которых не показываются в истории
+var secretFields = map[string]bool{"telegramToken": true, "smtpPassword": true}
+
+// SettingsVersion представляет сохраненную версию настроек.
+// @Description Версия настроек: снимок и отличия от предыдущей.
+type SettingsVersion struct {
+	// Version — номер версии
+	// example: 3
+	Version int `json:"version"`
+	// At — дата и время сохранения
+	At time.Time `json:"at"`
+	// Changes — отличия от предыдущей версии
+	Changes []FieldChange `json:"changes"`
+	// RolledBackFrom — номер версии, к которой откатились; 0 — обычное сохранение
+	// example: 0
+	RolledBackFrom int `json:"rolledBackFrom,omitempty"`
+	// Settings — снимок настроек
+	Settings Settings `json:"settings"`
+}
+
+// ProfileVersion представляет сохраненную версию профиля.
+// @Description Версия профиля: снимок и отличия от предыдущей.
+type ProfileVersion struct {
+	// Version — номер версии
+	// example: 2
+	Version int `json:"version"`
+	// At — дата и время сохранения
+	At time.Time `json:"at"`
+	// Changes — отличия от предыдущей версии
+	Changes []FieldChange `json:"changes"`
+	// RolledBackFrom — номер версии, к которой откатились; 0 — обычное сохранение
+	// example: 0
+	RolledBackFrom int `json:"rolledBackFrom,omitempty"`
+	// Profile — снимок профиля
+	Profile UserProfile `json:"profile"`
+}
+
+// jsonFields разбирает значение в поля верхнего уровня его JSON-представления
+func jsonFields(v any) map[string]json.RawMessage {
+	b, _ := json.Marshal(v)
+	fields := map[string]json.RawMessage{}
+	json.Unmarshal(b, &fields)
+	return fields
+}
+
+// diffConfig сравнивает две версии по полям верхнего уровня JSON.
+// Значения записываются в JSON, строки — без кавычек; секреты маскируются.
+func diffConfig(old, new any) []FieldChange {
+	a, b := jsonFields(old), jsonFields(new)
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	changes := []FieldChange{}
+	for _, k := range keys {
+		if string(a[k]) == string(b[k]) {
+			continue
+		}
+		from, to := jsonString(string(a[k])), jsonString(string(b[k]))
+		if secretFields[k] {
+			from, to = maskSecret(from), maskSecret(to)
+		}
+		changes = append(changes, FieldChange{Field: k, From: from, To: to})
+	}
+	return changes
+}
+
+// jsonString снимает кавычки со строкового JSON-значения, остальные оставляет как есть
+func jsonString(raw string) string {
+	if s, err := strconv.Unquote(raw); err == nil {
+		return s
+	}
+	return raw
+}
+
+// maskSecret скрывает непустой секрет
+func maskSecret(v string) string {
+	if v == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// maskSettings возвращает копию настроек со скрытыми токенами и паролями.
+// Секреты только для записи: клиент видит маску и может прислать ее обратно без изменений.
+func maskSettings(set Settings) Settings {
+	set.TelegramToken = maskSecret(set.TelegramToken)
+	set.SMTPPassword = maskSecret(set.SMTPPassword)
+	return set
+}
+
+// GetSettingsHistoryHandler создает обработчик истории настроек
+// @Summary История настроек
+// @Description Версии настроек пользователя, новые в конце. Токены и пароли скрыты.
+// @Tags settings
+// @Param userId path string true "ID пользователя"
+// @Produce json
+// @Success 200 {array} SettingsVersion
+// @Security SessionToken
+// @Router /settings/{userId}/history [get]
+func GetSettingsHistoryHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		history := storage.SettingsHistory(userId)
+		for i := range history {
+			history[i].Settings = maskSettings(history[i].Settings)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+// RollbackSettingsHandler создает обработчик отката настроек
+// @Summary Откатить настройки
+// @Description Восстанавливает версию настроек как новую версию и пересчитывает охлаждение активных желаний
+// @Tags settings
+// @Param userId path string true "ID пользователя"
+// @Param version path int true "Номер версии"
+// @Produce json
+// @Success 200 {object} Settings
+// @Failure 404 {object} Problem "version not found"
+// @Security SessionToken
+// @Router /settings/{userId}/history/{version}/rollback [post]
+func RollbackSettingsHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userId := vars["userId"]
+		version, err := strconv.Atoi(vars["version"])
+		if err != nil {
+			writeFieldErrors(w, r, []FieldError{{Field: "version", Message: "must be an integer"}})
+			return
+		}
+
+		set, err := storage.RollbackSettings(r.Context(), userId, version)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "settings rolled back", "user_id", userId, "version", version)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(maskSettings(set))
+	}
+}
+
+// GetProfileHistoryHandler создает обработчик истории профиля
+// @Summary История профиля
+// @Description Версии профиля пользователя, новые в конце
+// @Tags profile
+// @Param nick path string true "Ник пользователя"
+// @Produce json
+// @Success 200 {array} ProfileVersion
+// @Security SessionToken
+// @Router /user/{nick}/history [get]
+func GetProfileHistoryHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nick := mux.Vars(r)["nick"]
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(storage.ProfileHistory(nick))
+	}
+}
+
+// RollbackProfileHandler создает обработчик отката профиля
+// @Summary Откатить профиль
+// @Description Восстанавливает версию профиля как новую версию и пересчитывает активные желания
+// @Tags profile
+// @Param nick path string true "Ник пользователя"
+// @Param version path int true "Номер версии"
+// @Produce json
+// @Success 200 {object} UserProfile
+// @Failure 404 {object} Problem "version not found"
+// @Security SessionToken
+// @Router /user/{nick}/history/{version}/rollback [post]
+func RollbackProfileHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		nick := vars["nick"]
+		version, err := strconv.Atoi(vars["version"])
+		if err != nil {
+			writeFieldErrors(w, r, []FieldError{{Field: "version", Message: "must be an integer"}})
+			return
+		}
+
+		p, err := storage.RollbackProfile(r.Context(), nick, version)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "profile rolled back", "user_id", nick, "version", version)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	}
+}
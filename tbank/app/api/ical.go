@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CalendarFeed — ссылка на iCalendar-ленту пользователя
+// @Description Ссылка для подписки на календарь.
+type CalendarFeed struct {
+	// Token — секретный токен ленты
+	// example: "9f86d081884c7d659a2feaa0c55ad015"
+	Token string `json:"token"`
+	// URL — адрес ленты для подписки
+	// example: "http://localhost:8080/api/v1/ical/9f86d081884c7d659a2feaa0c55ad015.ics"
+	URL string `json:"url"`
+}
+
+// newCalendarToken генерирует неугадываемый токен ленты
+func newCalendarToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// calendarFeedURL собирает адрес ленты от publicURL, а если он не задан — по входящему запросу
+func calendarFeedURL(r *http.Request, publicURL, token string) string {
+	if publicURL != "" {
+		return fmt.Sprintf("%s%s/ical/%s.ics", strings.TrimSuffix(publicURL, "/"), apiPrefix, token)
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s/ical/%s.ics", scheme, r.Host, apiPrefix, token)
+}
+
+// icalEscape экранирует текст по RFC 5545
+func icalEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// icalWriter пишет строки календаря с CRLF и переносом длинных строк по 75 байт
+type icalWriter struct {
+	w io.Writer
+}
+
+func (iw icalWriter) line(format string, args ...any) {
+	s := fmt.Sprintf(format, args...)
+	// строка продолжения начинается с пробела, поэтому ей остается 74 байта
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		// не режем многобайтовый символ UTF-8 посередине
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		io.WriteString(iw.w, s[:cut]+"\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	io.WriteString(iw.w, s+"\r\n")
+}
+
+func icalDate(t time.Time) string {
+	return t.Format("20060102")
+}
+
+func icalTimestamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// writeCalendar пишет ленту: событие на каждое активное желание и повторяющийся опрос
+func writeCalendar(out io.Writer, userId string, wishes []Wish, settings Settings) {
+	iw := icalWriter{out}
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//TWish//Wishes//RU")
+	iw.line("CALSCALE:GREGORIAN")
+	iw.line("METHOD:PUBLISH")
+	iw.line("X-WR-CALNAME:%s", icalEscape("TWish: "+userId))
+	iw.line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	iw.line("X-PUBLISHED-TTL:PT1H")
+
+	var anchor time.Time
+	for _, w := range wishes {
+		if anchor.IsZero() || w.CreatedAt.Before(anchor) {
+			anchor = w.CreatedAt
+		}
+
+		// покупку можно планировать, когда закончилось охлаждение и она стала комфортной
+		date := coolingEnd(w)
+		price := formatMoney(w.Price, w.Currency)
+		if w.BaseCurrency != "" && w.BaseCurrency != w.Currency {
+			price += " (" + formatMoney(w.ConvertedPrice, w.BaseCurrency) + ")"
+		}
+		desc := fmt.Sprintf("Цена: %s\nОхлаждение до: %s", price, coolingEnd(w).Format("02.01.2006"))
+		if cd, ok := comfortDate(w); ok {
+			desc += "\nКомфортная покупка с: " + cd.Format("02.01.2006")
+			if cd.After(date) {
+				date = cd
+			}
+		} else {
+			desc += "\nКомфортная покупка пока недостижима"
+		}
+
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:wish-%s@twish", w.ID)
+		iw.line("DTSTAMP:%s", icalTimestamp(w.UpdateAt))
+		iw.line("LAST-MODIFIED:%s", icalTimestamp(w.UpdateAt))
+		iw.line("SEQUENCE:%d", w.UpdateAt.Unix()-w.CreatedAt.Unix())
+		iw.line("DTSTART;VALUE=DATE:%s", icalDate(date))
+		iw.line("DTEND;VALUE=DATE:%s", icalDate(date.AddDate(0, 0, 1)))
+		iw.line("SUMMARY:%s", icalEscape("Можно решить: "+w.Title))
+		iw.line("DESCRIPTION:%s", icalEscape(desc))
+		if w.Category != "" {
+			iw.line("CATEGORIES:%s", icalEscape(w.Category))
+		}
+		iw.line("TRANSP:TRANSPARENT")
+		iw.line("END:VEVENT")
+	}
+
+	if sched, ok := parseSurveySchedule(settings.NotificationFreq); ok && !anchor.IsZero() {
+		start := sched.Next(anchor)
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:survey-%s@twish", icalEscape(userId))
+		iw.line("DTSTAMP:%s", icalTimestamp(anchor))
+		iw.line("DTSTART:%s", icalTimestamp(start))
+		iw.line("DURATION:PT15M")
+		iw.line("RRULE:FREQ=%s;INTERVAL=%d", sched.Freq, sched.Interval)
+		iw.line("SUMMARY:%s", icalEscape("Опрос TWish: всё ещё хотите?"))
+		iw.line("DESCRIPTION:%s", icalEscape(fmt.Sprintf("Активных желаний: %d", len(wishes))))
+		iw.line("END:VEVENT")
+	}
+
+	iw.line("END:VCALENDAR")
+}
+
+// IssueCalendarTokenHandler создает обработчик для выдачи (и перевыпуска) ссылки на ленту
+// @Summary Выдать ссылку на календарь
+// @Description Выдает новый токен iCalendar-ленты, предыдущая ссылка перестает работать
+// @Tags calendar
+// @Param userId path string true "ID пользователя"
+// @Produce json
+// @Success 200 {object} CalendarFeed
+// @Security SessionToken
+// @Router /calendar/{userId} [post]
+func IssueCalendarTokenHandler(storage *Storage, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		token := newCalendarToken()
+		storage.SetCalendarToken(r.Context(), userId, token)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CalendarFeed{Token: token, URL: calendarFeedURL(r, publicURL, token)})
+	}
+}
+
+// GetCalendarTokenHandler создает обработчик для получения текущей ссылки на ленту
+// @Summary Получить ссылку на календарь
+// @Description Возвращает текущую ссылку на iCalendar-ленту пользователя
+// @Tags calendar
+// @Param userId path string true "ID пользователя"
+// @Produce json
+// @Success 200 {object} CalendarFeed
+// @Failure 404 {object} Problem "not found"
+// @Security SessionToken
+// @Router /calendar/{userId} [get]
+func GetCalendarTokenHandler(storage *Storage, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		token, ok := storage.CalendarToken(userId)
+		if !ok {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "calendar feed is not issued")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CalendarFeed{Token: token, URL: calendarFeedURL(r, publicURL, token)})
+	}
+}
+
+// CalendarFeedHandler создает обработчик iCalendar-ленты
+// @Summary iCalendar-лента
+// @Description Лента с датами окончания охлаждения активных желаний и повторяющимися опросами
+// @Tags calendar
+// @Param token path string true "Токен ленты"
+// @Produce text/calendar
+// @Success 200 {string} string "VCALENDAR"
+// @Failure 404 {object} Problem "not found"
+// @Router /ical/{token}.ics [get]
+func CalendarFeedHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := mux.Vars(r)["token"]
+		userId, ok := storage.UserByCalendarToken(token)
+		if !ok {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "calendar feed not found")
+			return
+		}
+
+		var buf bytes.Buffer
+		writeCalendar(&buf, userId, storage.GetWishes(userId, StatusActive), storage.GetSettings(userId))
+
+		sum := sha256.Sum256(buf.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		logger("handler").DebugContext(r.Context(), "calendar feed served", "user_id", userId)
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="twish.ics"`)
+		w.Write(buf.Bytes())
+	}
+}
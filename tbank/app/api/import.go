@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxImportBody — ограничение размера тела запроса импорта
+const maxImportBody = 5 << 20
+
+// maxImportRows — ограничение количества строк в одном импорте
+const maxImportRows = 1000
+
+// importRow — строка импорта в исходном виде
+type importRow struct {
+	WishInput
+	// parseErr — ошибка разбора строки (например, нечисловая цена в CSV)
+	parseErr error
+}
+
+// ImportVerdict — результат проверки одной строки импорта
+// @Description Вердикт по строке импорта.
+type ImportVerdict struct {
+	// Row — номер строки (с единицы, без заголовка)
+	// example: 1
+	Row int `json:"row"`
+	// OK — строка прошла проверку
+	// example: true
+	OK bool `json:"ok"`
+	// Error — причина отказа
+	// example: "price must be positive"
+	Error string `json:"error,omitempty"`
+	// Errors — ошибки по полям, если строка не прошла правила validate
+	Errors []FieldError `json:"errors,omitempty"`
+	// Wish — желание, которое будет создано
+	Wish *Wish `json:"wish,omitempty"`
+}
+
+// ImportResult — ответ на импорт
+// @Description Результат импорта желаний.
+type ImportResult struct {
+	// DryRun — проверка без сохранения
+	DryRun bool `json:"dryRun"`
+	// Applied — пачка сохранена
+	Applied bool `json:"applied"`
+	// Total — всего строк
+	Total int `json:"total"`
+	// Valid — строк без ошибок
+	Valid int `json:"valid"`
+	// Rows — вердикты по строкам
+	Rows []ImportVerdict `json:"rows"`
+}
+
+// parseImportJSON разбирает массив желаний в JSON; неизвестные поля — ошибка.
+// Ошибку чтения обработчик отдает через writeDecodeError, как decodeJSON.
+func parseImportJSON(r io.Reader) ([]importRow, error) {
+	var rows []importRow
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// parseImportCSV разбирает CSV с заголовком title,price и необязательными
+// currency,category,notes,url,tags,priority,targetDate (порядок колонок любой, метки через «;»).
+// Цена разбирается parsePrice, так что «12 990», «1,000.50» и «1.299,00» тоже подходят.
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	cols := map[string]int{"title": -1, "price": -1, "currency": -1, "category": -1, "notes": -1, "url": -1, "tags": -1, "priority": -1, "targetdate": -1}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := cols[h]; ok {
+			cols[h] = i
+		}
+	}
+	if cols["title"] < 0 || cols["price"] < 0 {
+		return nil, errors.New("invalid csv: header must contain title and price")
+	}
+
+	field := func(rec []string, name string) string {
+		i := cols[name]
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	var rows []importRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		row := importRow{WishInput: WishInput{
+			Title:      field(rec, "title"),
+			Currency:   field(rec, "currency"),
+			Category:   field(rec, "category"),
+			Notes:      field(rec, "notes"),
+			URL:        field(rec, "url"),
+			Priority:   WishPriority(strings.ToLower(field(rec, "priority"))),
+			TargetDate: field(rec, "targetdate"),
+		}}
+		if tags := field(rec, "tags"); tags != "" {
+			row.Tags = strings.Split(tags, ";")
+		}
+		if row.Price, err = parsePrice(field(rec, "price")); err != nil {
+			row.parseErr = fmt.Errorf("invalid price %q", field(rec, "price"))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importFormat определяет формат по параметру format или Content-Type
+func importFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+// ImportHandler создает обработчик для массового импорта желаний
+// @Summary Импортировать желания
+// @Description Загружает желания из CSV или JSON. Каждая строка проверяется так же, как при добавлении одного желания. При dryRun=true возвращаются только вердикты; иначе пачка сохраняется целиком или не сохраняется вовсе.
+// @Tags import
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param userId path string true "ID пользователя"
+// @Param format query string false "csv или json"
+// @Param dryRun query bool false "Только проверить"
+// @Success 200 {object} ImportResult
+// @Failure 400 {object} Problem "bad_request или invalid_json"
+// @Failure 413 {object} Problem "payload_too_large"
+// @Failure 422 {object} ImportResult
+// @Security SessionToken
+// @Router /import/{userId} [post]
+func ImportHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+		body := http.MaxBytesReader(w, r.Body, maxImportBody)
+
+		var rows []importRow
+		var err error
+		switch format := importFormat(r); format {
+		case "csv":
+			if rows, err = parseImportCSV(body); err != nil {
+				writeError(w, r, badRequest(err))
+				return
+			}
+		case "json":
+			if rows, err = parseImportJSON(body); err != nil {
+				writeDecodeError(w, r, err)
+				return
+			}
+		default:
+			writeFieldErrors(w, r, []FieldError{{Field: "format", Message: "must be csv or json"}})
+			return
+		}
+		if len(rows) > maxImportRows {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("too many rows (max %d)", maxImportRows))
+			return
+		}
+
+		settings := storage.GetSettings(userId)
+		profile, _ := storage.GetProfile(userId)
+		rates := storage.Rates()
+		lang := requestLang(r)
+
+		res := ImportResult{DryRun: dryRun, Total: len(rows), Rows: make([]ImportVerdict, 0, len(rows))}
+		batch := make([]Wish, 0, len(rows))
+		for i, row := range rows {
+			v := ImportVerdict{Row: i + 1}
+			err := row.parseErr
+			if err == nil {
+				if errs := validateStruct(&row.WishInput); len(errs) > 0 {
+					v.Errors = localizeFieldErrors(lang, errs)
+					err = fieldErrors(v.Errors)
+				}
+			}
+			var wish Wish
+			if err == nil {
+				wish, err = newWish(row.WishInput, settings, profile, rates)
+			}
+			if err != nil {
+				v.Error = err.Error()
+			} else {
+				v.OK = true
+				v.Wish = &wish
+				batch = append(batch, wish)
+				res.Valid++
+			}
+			res.Rows = append(res.Rows, v)
+		}
+
+		status := http.StatusOK
+		switch {
+		case dryRun:
+		case res.Valid != res.Total:
+			status = http.StatusUnprocessableEntity
+		case len(batch) > 0:
+			storage.AddWishes(r.Context(), userId, batch)
+			res.Applied = true
+		}
+
+		logger("handler").InfoContext(r.Context(), "wishes import", "user_id", userId, "valid", res.Valid, "total", res.Total, "dry_run", dryRun, "applied", res.Applied)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(res)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestParseImportCSVPrices(t *testing.T) {
+	csvBody := "title,price\n" +
+		"a,1290\n" +
+		"b,\"12,5\"\n" +
+		"c,\"1,000.50\"\n" +
+		"d,\"1.299,00\"\n" +
+		"e,12 990\n" +
+		"f,дорого\n"
+	rows, err := parseImportCSV(strings.NewReader(csvBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1290, 12.5, 1000.5, 1299, 12990}
+	for i, p := range want {
+		if rows[i].parseErr != nil || rows[i].Price != p {
+			t.Errorf("row %s: price = %v (%v), want %v", rows[i].Title, rows[i].Price, rows[i].parseErr, p)
+		}
+	}
+	if rows[5].parseErr == nil {
+		t.Error("non-numeric price is accepted")
+	}
+}
+
+func importRequest(t *testing.T, storage *Storage, contentType, body string) (*httptest.ResponseRecorder, ImportResult) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/import/u1?dryRun=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	rec := httptest.NewRecorder()
+	ImportHandler(storage)(rec, req)
+	var res ImportResult
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec, res
+}
+
+func TestImportJSONRejectsUnknownFields(t *testing.T) {
+	rec, _ := importRequest(t, NewStorage(), "application/json", `[{"title":"Книга","price":500,"prise":1}]`)
+	if rec.Code != 400 || !strings.Contains(rec.Body.String(), codeInvalidJSON) {
+		t.Errorf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImportReportsFieldErrors(t *testing.T) {
+	body := `[{"title":"Книга","price":500},{"title":"` + strings.Repeat("я", 201) + `","price":500}]`
+	rec, res := importRequest(t, NewStorage(), "application/json", body)
+	if rec.Code != 200 || res.Total != 2 || res.Valid != 1 {
+		t.Fatalf("status = %d, result = %+v", rec.Code, res)
+	}
+	bad := res.Rows[1]
+	if bad.OK || len(bad.Errors) != 1 || bad.Errors[0].Field != "title" || bad.Errors[0].Rule != "maxlen" {
+		t.Errorf("verdict = %+v", bad)
+	}
+}
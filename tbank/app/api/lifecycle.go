@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reopenGracePeriod — сколько времени после покупки или отмены можно вернуть желание в активные
+const reopenGracePeriod = 24 * time.Hour
+
+// errIllegalTransition — переход между статусами не разрешен
+var errIllegalTransition = errors.New("illegal status transition")
+
+// wishTransitions — разрешенные переходы: из статуса -> в статусы
+var wishTransitions = map[WishStatus][]WishStatus{
+	StatusActive:    {StatusCompleted, StatusCanceled},
+	StatusCompleted: {StatusActive},
+	StatusCanceled:  {StatusActive},
+}
+
+// wishActions — действия PUT /wishes/{userId}/{wishId}?action= и целевые статусы.
+// reopen возвращает в активные и выполненное, и отмененное желание.
+var wishActions = map[string]WishStatus{
+	"complete": StatusCompleted,
+	"cancel":   StatusCanceled,
+	"reopen":   StatusActive,
+}
+
+// checkTransition проверяет, можно ли перевести желание в статус to в момент now.
+// Выполненное или отмененное желание можно вернуть в активные только в течение reopenGracePeriod.
+func checkTransition(w Wish, to WishStatus, now time.Time) error {
+	allowed := false
+	for _, s := range wishTransitions[w.Status] {
+		if s == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", errIllegalTransition, w.Status, to)
+	}
+
+	if w.Status != StatusActive && to == StatusActive {
+		changed := w.StatusChangedAt
+		if changed.IsZero() {
+			changed = w.UpdateAt
+		}
+		if now.Sub(changed) > reopenGracePeriod {
+			return fmt.Errorf("%w: reopen period of %s has expired", errIllegalTransition, reopenGracePeriod)
+		}
+	}
+	return nil
+}
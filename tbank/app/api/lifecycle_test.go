@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckTransitionGracePeriod(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		from    WishStatus
+		changed time.Time
+		wantErr bool
+	}{
+		{"reopen within period", StatusCompleted, now.Add(-time.Hour), false},
+		{"reopen after period", StatusCompleted, now.Add(-reopenGracePeriod - time.Minute), true},
+		{"restore within period", StatusCanceled, now.Add(-time.Hour), false},
+		{"restore after period", StatusCanceled, now.Add(-reopenGracePeriod - time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransition(Wish{Status: tt.from, StatusChangedAt: tt.changed}, StatusActive, now)
+			if got := errors.Is(err, errIllegalTransition); got != tt.wantErr {
+				t.Errorf("checkTransition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
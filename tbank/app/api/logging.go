@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// newLogger создает JSON-логгер уровня level. В каждую запись, сделанную с контекстом запроса,
+// добавляется request_id.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// setupLogging делает JSON-логгер логгером по умолчанию. Старые вызовы log.Printf
+// тоже попадают в него как записи уровня INFO.
+func setupLogging(w io.Writer, level slog.Level) {
+	slog.SetDefault(newLogger(w, level))
+	log.SetFlags(0)
+}
+
+// logger возвращает логгер компонента: storage, handler, notify, pricewatch, http
+func logger(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// contextHandler добавляет в запись ID запроса из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestUserKey — ключ контекста, под которым лежит *requestUser
+type requestUserKey struct{}
+
+// requestUser — пользователь запроса; его заполняют обработчики глубже по цепочке,
+// а читает accessLogMiddleware после ответа
+type requestUser struct {
+	id string
+}
+
+// setRequestUser запоминает пользователя запроса для журнала доступа
+func setRequestUser(ctx context.Context, userId string) {
+	if u, ok := ctx.Value(requestUserKey{}).(*requestUser); ok {
+		u.id = userId
+	}
+}
+
+// accessLogMiddleware пишет по записи на запрос: метод, шаблон маршрута, статус, длительность
+// и пользователь. Путь целиком не пишется, чтобы ники из URL не попадали в логи.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		user := &requestUser{}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestUserKey{}, user)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", routeTemplate(r)),
+			slog.Int("status", rec.status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if user.id != "" {
+			attrs = append(attrs, slog.String("user_id", user.id))
+		}
+		logger("http").LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
@@ -1,39 +1,133 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-
-	httpSwagger "github.com/swaggo/http-swagger"
+	"os"
+	"slices"
+	"time"
 )
 
 // @title TWish API
 // @version 1.0
-// @description MeowMeow ./<|<3|>\.
+// @description MeowMeow ./<|<3|>.
 // @host localhost:8080
-// @BasePath /
+// @BasePath /api/v1
 
-// @securityDefinitions.basic  BasicAuth
+// @securityDefinitions.apikey SessionToken
+// @in header
+// @name Authorization
+// @description Токен из POST /session в виде "Bearer <token>"; вместо заголовка можно передать cookie twish_session
 func main() {
+	log.SetOutput(NewRedactingWriter(os.Stderr))
+
+	cfg, printConfig, err := LoadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	level, _ := cfg.SlogLevel()
+	setupLogging(NewRedactingWriter(os.Stderr), level)
+
 	storage := NewStorage()
-	router := NewRouter(storage)
+	if cfg.Security.SecretKey != "" {
+		key, err := ParseSecretKey(cfg.Security.SecretKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		box, err := NewSecretBox(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		storage.SetSecretBox(box)
+	} else {
+		slog.Warn("TWISH_SECRET_KEY is not set, notification credentials are encrypted with a temporary key")
+	}
+	if cfg.RatesFile != "" {
+		rates, err := LoadRatesFile(cfg.RatesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		storage.SetRateProvider(rates)
+		slog.Info("loaded currency rates", "count", len(rates.Rates), "file", cfg.RatesFile)
+	}
+
+	sessionKey := RandomSecretKey()
+	if cfg.Security.SessionKey != "" {
+		key, err := ParseSecretKey(cfg.Security.SessionKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sessionKey = key
+	} else {
+		slog.Warn("TWISH_SESSION_KEY is not set, sessions are signed with a temporary key and end on restart")
+	}
+	sessions := NewSessionManager(storage, sessionKey)
+	if !cfg.Security.RequireSession {
+		sessions.Required = false
+		slog.Warn("TWISH_REQUIRE_SESSION is off, user data is accessible without signing in")
+	}
+	notifier := NewNotifier(storage, cfg)
+	watcher := NewPriceWatcher(storage, NewHTTPPriceFetcher(), time.Duration(cfg.Scheduler.PriceWatchInterval), notifier.Dispatch)
+	router := NewRouter(storage, sessions, notifier, cfg.PublicURL)
+	registerOpsRoutes(router,
+		ReadinessCheck{Name: "storage", Check: storage.Ping},
+		ReadinessCheck{Name: "scheduler", Check: watcher.Alive},
+	)
+
+	handler := corsMiddleware(cfg.AllowedOrigins)(requestIDMiddleware(router))
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	http.Handle("/api/notify/", http.HandlerFunc(notifyHandler))
-	http.Handle("/swagger/*", httpSwagger.WrapHandler)
+	app := NewServer(server, time.Duration(cfg.ShutdownTimeout))
+	app.Go("price watcher", watcher.Run)
+	app.Go("notifier", notifier.Run)
+	app.OnStop("storage", storage.Flush)
+	app.OnStop("notifications", notifier.Flush)
 
-	handler := func(h http.Handler) http.Handler {
+	if err := app.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// corsMiddleware разрешает запросы из allowedOrigins; "*" разрешает любой источник
+func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowAny := slices.Contains(allowedOrigins, "*")
+	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			origin := r.Header.Get("Origin")
+			switch {
+			case allowAny:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && slices.Contains(allowedOrigins, origin):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, X-Next-Cursor, X-Request-ID")
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusOK)
 				return
 			}
 			h.ServeHTTP(w, r)
 		})
-	}(router)
-
-	log.Println("Server starting on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	}
 }
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// mergePatchContentType — тип тела JSON Merge Patch (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// errPatchNotObject — тело патча должно быть JSON-объектом
+var errPatchNotObject = errors.New("merge patch must be a JSON object")
+
+// fieldErrors — ошибки по полям, возвращаемые из функций изменения хранилища
+type fieldErrors []FieldError
+
+func (e fieldErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// mergePatch применяет патч к документу по RFC 7396: null удаляет ключ,
+// объекты сливаются рекурсивно, остальные значения заменяются целиком
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// jsonFieldTypes возвращает типы полей структуры по именам из json-тегов
+func jsonFieldTypes(t reflect.Type) map[string]reflect.Type {
+	out := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		out[name] = f.Type
+	}
+	return out
+}
+
+// fieldPath собирает путь к вложенному полю в виде cooldowns[0].min
+func fieldPath(root, nested string) string {
+	var b strings.Builder
+	b.WriteString(root)
+	for _, part := range strings.Split(nested, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+		} else {
+			b.WriteString("." + part)
+		}
+	}
+	return b.String()
+}
+
+// typeName описывает ожидаемый тип поля для сообщения об ошибке
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return t.String()
+}
+
+// applyJSONMergePatch накладывает патч на копию current и возвращает результат.
+// Неизвестные поля и значения неверного типа возвращаются как fieldErrors.
+func applyJSONMergePatch[T any](current T, patch []byte) (T, error) {
+	var zero T
+
+	var p map[string]any
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil || p == nil {
+		return zero, errPatchNotObject
+	}
+
+	types := jsonFieldTypes(reflect.TypeOf(current))
+	var errs fieldErrors
+	for k, v := range p {
+		ft, ok := types[k]
+		if !ok {
+			errs = append(errs, FieldError{Field: k, Message: "unknown field"})
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		raw, _ := json.Marshal(v)
+		if err := json.Unmarshal(raw, reflect.New(ft).Interface()); err != nil {
+			fe := FieldError{Field: k, Message: "must be " + typeName(ft)}
+			var te *json.UnmarshalTypeError
+			if errors.As(err, &te) && te.Field != "" {
+				fe = FieldError{Field: fieldPath(k, te.Field), Message: "must be " + typeName(te.Type)}
+			}
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return zero, errs
+	}
+
+	var doc any
+	b, _ := json.Marshal(current)
+	json.Unmarshal(b, &doc)
+	merged, _ := json.Marshal(mergePatch(doc, p))
+
+	var out T
+	dec = json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return zero, badRequest(fmt.Errorf("invalid merge patch: %w", err))
+	}
+	return out, nil
+}
+
+// readMergePatch проверяет тип тела и читает патч
+func readMergePatch(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, _ := mime.ParseMediaType(ct)
+		if mt != mergePatchContentType && mt != "application/json" {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "unsupported content type, expected "+mergePatchContentType)
+			return nil, false
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	return body, true
+}
+
+// PatchSettingsHandler создает обработчик частичного обновления настроек
+// @Summary Частично изменить настройки
+// @Description Применяет JSON Merge Patch (RFC 7396): переданные поля заменяются, null сбрасывает поле, остальные не меняются. Охлаждение активных желаний пересчитывается
+// @Tags settings
+// @Param userId path string true "ID пользователя"
+// @Param patch body Settings true "Изменяемые поля"
+// @Accept application/merge-patch+json
+// @Produce json
+// @Success 200 {object} Settings
+// @Failure 400 {object} Problem "validation_failed"
+// @Failure 415 {object} Problem "unsupported content type"
+// @Security SessionToken
+// @Router /settings/{userId} [patch]
+func PatchSettingsHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		patch, ok := readMergePatch(w, r)
+		if !ok {
+			return
+		}
+
+		set, err := storage.UpdateSettings(r.Context(), userId, func(cur Settings) (Settings, error) {
+			next, err := applyJSONMergePatch(cur, patch)
+			if err != nil {
+				return cur, err
+			}
+			if errs := append(validateStruct(next), validateSettings(next)...); len(errs) > 0 {
+				return cur, fieldErrors(errs)
+			}
+			return next, nil
+		})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").DebugContext(r.Context(), "settings patched", "user_id", userId)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(maskSettings(set))
+	}
+}
+
+// PatchProfileHandler создает обработчик частичного обновления профиля
+// @Summary Частично изменить профиль
+// @Description Применяет JSON Merge Patch (RFC 7396) к профилю; ник берется из пути
+// @Tags profile
+// @Param nick path string true "Ник пользователя"
+// @Param patch body UserProfile true "Изменяемые поля"
+// @Accept application/merge-patch+json
+// @Produce json
+// @Success 200 {object} UserProfile
+// @Failure 400 {object} Problem "validation_failed"
+// @Failure 415 {object} Problem "unsupported content type"
+// @Security SessionToken
+// @Router /user/{nick} [patch]
+func PatchProfileHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nick := mux.Vars(r)["nick"]
+		patch, ok := readMergePatch(w, r)
+		if !ok {
+			return
+		}
+
+		rates := storage.Rates()
+		p, err := storage.UpdateProfile(r.Context(), nick, func(cur UserProfile) (UserProfile, error) {
+			next, err := applyJSONMergePatch(cur, patch)
+			if err != nil {
+				return cur, err
+			}
+			if errs := append(validateStruct(next), validateProfile(next, rates)...); len(errs) > 0 {
+				return cur, fieldErrors(errs)
+			}
+			return next, nil
+		})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").DebugContext(r.Context(), "profile patched", "user_id", nick)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// примеры из приложения A RFC 7396
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		var target, patch, want any
+		json.Unmarshal([]byte(tt.target), &target)
+		json.Unmarshal([]byte(tt.patch), &patch)
+		json.Unmarshal([]byte(tt.want), &want)
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestApplyJSONMergePatch(t *testing.T) {
+	current := Settings{
+		Cooldowns:        []CooldownRange{{Min: 0, Period: 7, Unbounded: true}},
+		NotificationFreq: "daily",
+		Email:            "cat@example.com",
+		MonthlySaving:    5000,
+	}
+
+	got, err := applyJSONMergePatch(current, []byte(`{"email":null,"monthlySaving":7000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "" || got.MonthlySaving != 7000 {
+		t.Errorf("patched fields: email %q, monthlySaving %v", got.Email, got.MonthlySaving)
+	}
+	if got.NotificationFreq != "daily" || !reflect.DeepEqual(got.Cooldowns, current.Cooldowns) {
+		t.Errorf("fields absent from the patch changed: %+v", got)
+	}
+	if current.Email != "cat@example.com" {
+		t.Error("applyJSONMergePatch modified the current value")
+	}
+
+	got, err = applyJSONMergePatch(current, []byte(`{"cooldowns":[{"min":0,"max":100,"period":1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []CooldownRange{{Min: 0, Max: 100, Period: 1}}; !reflect.DeepEqual(got.Cooldowns, want) {
+		t.Errorf("arrays must be replaced as a whole: %v", got.Cooldowns)
+	}
+}
+
+func TestApplyJSONMergePatchErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		patch  string
+		fields []string
+	}{
+		{"unknown field", `{"colour":"red"}`, []string{"colour"}},
+		{"wrong type", `{"monthlySaving":"a lot"}`, []string{"monthlySaving"}},
+		{"nested wrong type", `{"cooldowns":[{"min":"zero"}]}`, []string{"cooldowns[0].min"}},
+		{"sorted by field", `{"zzz":1,"email":5}`, []string{"email", "zzz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyJSONMergePatch(Settings{}, []byte(tt.patch))
+			var errs fieldErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("error = %v, want fieldErrors", err)
+			}
+			var fields []string
+			for _, fe := range errs {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+
+	for _, patch := range []string{`[]`, `"x"`, `null`, `{`} {
+		if _, err := applyJSONMergePatch(Settings{}, []byte(patch)); !errors.Is(err, errPatchNotObject) {
+			t.Errorf("patch %s: error = %v, want %v", patch, err, errPatchNotObject)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultLang — язык сообщений, если клиент не прислал подходящий Accept-Language
+const defaultLang = "en"
+
+// validationMessages — сообщения правил валидации по языкам; {param} заменяется параметром правила
+var validationMessages = map[string]map[string]string{
+	"en": {
+		"required":       "is required",
+		"gt":             "must be greater than {param}",
+		"gte":            "must be at least {param}",
+		"lte":            "must be at most {param}",
+		"maxlen":         "must be at most {param} characters",
+		"maxlen.items":   "must have at most {param} items",
+		"oneof":          "must be one of: {param}",
+		"email":          "must be a valid email address",
+		"url":            "must be an absolute http(s) link",
+		"finite":         "must be a finite number",
+		"invalid_fields": "request has invalid fields",
+	},
+	"ru": {
+		"required":       "обязательное поле",
+		"gt":             "должно быть больше {param}",
+		"gte":            "должно быть не меньше {param}",
+		"lte":            "должно быть не больше {param}",
+		"maxlen":         "не длиннее {param} символов",
+		"maxlen.items":   "не больше {param} элементов",
+		"oneof":          "допустимые значения: {param}",
+		"email":          "некорректный адрес почты",
+		"url":            "нужна абсолютная http(s)-ссылка",
+		"finite":         "должно быть конечным числом",
+		"invalid_fields": "в запросе есть ошибки в полях",
+	},
+}
+
+// requestLang выбирает язык сообщений по заголовку Accept-Language с учетом q-весов
+func requestLang(r *http.Request) string {
+	best, bestQ := defaultLang, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := validationMessages[lang]; !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// localize возвращает сообщение key на языке lang; неизвестный ключ дает fallback
+func localize(lang, key, param, fallback string) string {
+	msg, ok := validationMessages[lang][key]
+	if !ok {
+		return fallback
+	}
+	if key == "oneof" {
+		param = strings.Join(strings.Fields(param), ", ")
+	}
+	return strings.ReplaceAll(msg, "{param}", param)
+}
+
+// localizeFieldErrors переводит сообщения ошибок, найденных по правилам validate
+func localizeFieldErrors(lang string, errs []FieldError) []FieldError {
+	out := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		if fe.key != "" {
+			fe.Message = localize(lang, fe.key, fe.Param, fe.Message)
+		}
+		out[i] = fe
+	}
+	return out
+}
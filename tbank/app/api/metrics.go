@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// metrics — счетчики сервера, которые отдает /metrics в текстовом формате Prometheus
+var metrics = NewMetrics()
+
+// durationBuckets — границы гистограммы длительности запросов, в секундах
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics собирает счетчики запросов, желаний, уведомлений и отставание планировщиков
+type Metrics struct {
+	mu            sync.Mutex
+	requests      map[[3]string]uint64 // method, route, status
+	durations     map[[2]string]*histogram
+	wishes        map[string]uint64    // событие: created, completed, canceled, reopened
+	notifications map[[2]string]uint64 // channel, result
+	schedulers    map[string]func(now time.Time) time.Duration
+}
+
+type histogram struct {
+	counts []uint64 // по durationBuckets, без +Inf
+	count  uint64
+	sum    float64
+}
+
+// NewMetrics создает пустой набор счетчиков
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:      map[[3]string]uint64{},
+		durations:     map[[2]string]*histogram{},
+		wishes:        map[string]uint64{},
+		notifications: map[[2]string]uint64{},
+		schedulers:    map[string]func(time.Time) time.Duration{},
+	}
+}
+
+// ObserveRequest учитывает запрос к маршруту route с ответом status
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[3]string{method, route, strconv.Itoa(status)}]++
+
+	key := [2]string{method, route}
+	h := m.durations[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[key] = h
+	}
+	sec := d.Seconds()
+	for i, le := range durationBuckets {
+		if sec <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += sec
+}
+
+// WishEvent учитывает n событий жизненного цикла желаний
+func (m *Metrics) WishEvent(event string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wishes[event] += uint64(n)
+}
+
+// NotificationResult учитывает отправку уведомления по каналу: sent при err == nil, иначе failed
+func (m *Metrics) NotificationResult(channel string, err error) {
+	result := "sent"
+	if err != nil {
+		result = "failed"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifications[[2]string{channel, result}]++
+}
+
+// RegisterScheduler добавляет задачу, отставание которой от расписания считается при каждом сборе
+func (m *Metrics) RegisterScheduler(job string, lag func(now time.Time) time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedulers[job] = lag
+}
+
+// WriteTo пишет все счетчики в текстовом формате Prometheus 0.0.4
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	header := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("twish_http_requests_total", "counter", "HTTP requests by method, route template and status.")
+	for _, k := range sortedKeys(m.requests, func(k [3]string) string { return strings.Join(k[:], "\x00") }) {
+		fmt.Fprintf(&b, "twish_http_requests_total{method=%s,route=%s,status=%s} %d\n",
+			labelValue(k[0]), labelValue(k[1]), labelValue(k[2]), m.requests[k])
+	}
+
+	header("twish_http_request_duration_seconds", "histogram", "HTTP request latency by method and route template.")
+	for _, k := range sortedKeys(m.durations, func(k [2]string) string { return strings.Join(k[:], "\x00") }) {
+		h := m.durations[k]
+		labels := fmt.Sprintf("method=%s,route=%s", labelValue(k[0]), labelValue(k[1]))
+		for i, le := range durationBuckets {
+			fmt.Fprintf(&b, "twish_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(le), h.counts[i])
+		}
+		fmt.Fprintf(&b, "twish_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "twish_http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "twish_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	header("twish_wishes_total", "counter", "Wish lifecycle events: created, completed, canceled, reopened.")
+	for _, event := range sortedKeys(m.wishes, func(k string) string { return k }) {
+		fmt.Fprintf(&b, "twish_wishes_total{event=%s} %d\n", labelValue(event), m.wishes[event])
+	}
+
+	header("twish_notifications_total", "counter", "Notifications by channel and result.")
+	for _, k := range sortedKeys(m.notifications, func(k [2]string) string { return strings.Join(k[:], "\x00") }) {
+		fmt.Fprintf(&b, "twish_notifications_total{channel=%s,result=%s} %d\n", labelValue(k[0]), labelValue(k[1]), m.notifications[k])
+	}
+
+	header("twish_scheduler_lag_seconds", "gauge", "How far a background job is behind its schedule.")
+	now := time.Now()
+	for _, job := range sortedKeys(m.schedulers, func(k string) string { return k }) {
+		fmt.Fprintf(&b, "twish_scheduler_lag_seconds{job=%s} %s\n", labelValue(job),
+			strconv.FormatFloat(m.schedulers[job](now).Seconds(), 'f', 3, 64))
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, key func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return key(keys[i]) < key(keys[j]) })
+	return keys
+}
+
+// labelValue экранирует значение метки по правилам текстового формата Prometheus
+func labelValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+// statusRecorder запоминает код ответа для метрик и журнала доступа
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// metricsMiddleware считает запросы и их длительность по шаблону маршрута, а не по пути,
+// чтобы ID пользователей и желаний не раздували число рядов
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		metrics.ObserveRequest(r.Method, routeTemplate(r), rec.status, time.Since(start))
+	})
+}
+
+// routeTemplate возвращает шаблон маршрута запроса или "unmatched", если маршрут не найден
+func routeTemplate(r *http.Request) string {
+	if cur := mux.CurrentRoute(r); cur != nil {
+		if tpl, err := cur.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// MetricsHandler отдает счетчики в формате Prometheus
+func MetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.WriteTo(w)
+	}
+}
+
+// wishEventName возвращает событие twish_wishes_total для перехода желания в статус to
+func wishEventName(to WishStatus) string {
+	if to == StatusActive {
+		return "reopened"
+	}
+	return string(to)
+}
@@ -2,6 +2,57 @@ package main
 
 import "time"
 
+// WishStatus — статус желания
+type WishStatus string
+
+const (
+	// StatusActive — желание «остывает» и ждет решения
+	StatusActive WishStatus = "active"
+	// StatusCompleted — желание исполнено (покупка сделана)
+	StatusCompleted WishStatus = "completed"
+	// StatusCanceled — от желания отказались
+	StatusCanceled WishStatus = "canceled"
+)
+
+// wishStatuses — все известные статусы в порядке жизненного цикла
+var wishStatuses = []WishStatus{StatusActive, StatusCompleted, StatusCanceled}
+
+// Valid сообщает, является ли статус известным
+func (s WishStatus) Valid() bool {
+	for _, v := range wishStatuses {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// WishPriority — приоритет желания
+type WishPriority string
+
+const (
+	PriorityLow    WishPriority = "low"
+	PriorityNormal WishPriority = "normal"
+	PriorityHigh   WishPriority = "high"
+)
+
+// Rank возвращает вес приоритета: чем больше, тем важнее
+func (p WishPriority) Rank() int {
+	switch p {
+	case PriorityHigh:
+		return 2
+	case PriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Valid сообщает, является ли приоритет известным
+func (p WishPriority) Valid() bool {
+	return p == PriorityLow || p == PriorityNormal || p == PriorityHigh
+}
+
 // Wish представляет желание пользователя.
 // @Description Информация о желании пользователя.
 type Wish struct {
@@ -11,9 +62,18 @@ type Wish struct {
 	// Title — название желания
 	// example: "Новый Ноутбук"
 	Title string `json:"title"`
-	// Price — цена в рублях
+	// Price — цена в валюте желания
 	// example: 10 000
 	Price float64 `json:"price"`
+	// Currency — валюта цены (ISO 4217)
+	// example: "USD"
+	Currency string `json:"currency"`
+	// ConvertedPrice — цена в базовой валюте профиля; по ней считаются охлаждение и комфорт
+	// example: 920000
+	ConvertedPrice float64 `json:"convertedPrice"`
+	// BaseCurrency — базовая валюта профиля на момент пересчета
+	// example: "RUB"
+	BaseCurrency string `json:"baseCurrency"`
 	// Category — категория желания
 	// example: "Электроника"
 	Category string `json:"category"`
@@ -28,16 +88,106 @@ type Wish struct {
 	StillWant bool `json:"stillWant"`
 	// Status — статус желания
 	// example: "active"
-	Status string `json:"status"`
+	Status WishStatus `json:"status" enums:"active,completed,canceled"`
 	// CreatedAt — дата и время создания желания
 	// example: "2024-01-01T12:00:00Z"
 	CreatedAt time.Time `json:"createdAt"`
+	// StatusChangedAt — дата и время последней смены статуса
+	// example: "2024-01-04T09:00:00Z"
+	StatusChangedAt time.Time `json:"statusChangedAt,omitzero"`
 	// UpdateAt — дата и время последнего обновления желания
 	// example: "2024-01-05T15:30:00Z"
 	UpdateAt time.Time `json:"updateAt"`
 	// ComfortMonths — количество месяцев комфорта для желания
 	// example: 3
 	ComfortMonths int `json:"comfortMonths"`
+	// Notes — заметка в markdown: почему хочется
+	// example: "Старый **уже тормозит**"
+	Notes string `json:"notes,omitempty"`
+	// Tags — произвольные метки
+	// example: ["работа","подарок"]
+	Tags []string `json:"tags,omitempty"`
+	// Priority — приоритет
+	// example: "high"
+	Priority WishPriority `json:"priority" enums:"low,normal,high"`
+	// TargetDate — желаемая дата покупки
+	// example: "2024-06-01T00:00:00Z"
+	TargetDate time.Time `json:"targetDate,omitzero"`
+	// URL — ссылка на товар
+	// example: "https://example.com/laptop"
+	URL string `json:"url,omitempty"`
+	// CoolingStartedAt — момент (пере)запуска охлаждения; пусто — считается от CreatedAt
+	// example: "2024-01-03T10:00:00Z"
+	CoolingStartedAt time.Time `json:"coolingStartedAt,omitzero"`
+	// Edits — история изменений желания
+	Edits []WishEdit `json:"edits,omitempty"`
+}
+
+// BasePrice возвращает цену в базовой валюте профиля
+func (w Wish) BasePrice() float64 {
+	if w.BaseCurrency == "" {
+		return w.Price
+	}
+	return w.ConvertedPrice
+}
+
+// WishInput — поля нового желания от клиента
+// @Description Данные для создания желания.
+type WishInput struct {
+	// Title — название желания
+	// example: "Новый Ноутбук"
+	Title string `json:"title" validate:"required,maxlen=200"`
+	// Price — цена
+	// example: 10000
+	Price float64 `json:"price" validate:"gt=0"`
+	// Currency — валюта цены (ISO 4217); пусто — валюта профиля
+	// example: "USD"
+	Currency string `json:"currency" validate:"maxlen=3"`
+	// Category — категория желания
+	// example: "Электроника"
+	Category string `json:"category" validate:"maxlen=100"`
+	// Notes — заметка в markdown
+	// example: "Старый **уже тормозит**"
+	Notes string `json:"notes" validate:"maxlen=2000"`
+	// URL — ссылка на товар
+	// example: "https://example.com/laptop"
+	URL string `json:"url" validate:"url,maxlen=2048"`
+	// Tags — произвольные метки
+	// example: ["работа"]
+	Tags []string `json:"tags" validate:"maxlen=10,itemmaxlen=30"`
+	// Priority — приоритет: low, normal, high
+	// example: "normal"
+	Priority WishPriority `json:"priority" enums:"low,normal,high" validate:"oneof=low normal high"`
+	// TargetDate — желаемая дата покупки, YYYY-MM-DD или RFC 3339
+	// example: "2024-06-01"
+	TargetDate string `json:"targetDate" validate:"maxlen=35"`
+}
+
+// WishEdit представляет запись истории изменений желания.
+// @Description Одно редактирование желания.
+type WishEdit struct {
+	// At — дата и время изменения
+	// example: "2024-01-03T10:00:00Z"
+	At time.Time `json:"at"`
+	// Changes — измененные поля
+	Changes []FieldChange `json:"changes"`
+	// CoolingRestarted — охлаждение запущено заново
+	// example: false
+	CoolingRestarted bool `json:"coolingRestarted"`
+}
+
+// FieldChange представляет изменение одного поля.
+// @Description Старое и новое значение поля.
+type FieldChange struct {
+	// Field — имя поля
+	// example: "price"
+	Field string `json:"field"`
+	// From — старое значение
+	// example: "10000"
+	From string `json:"from"`
+	// To — новое значение
+	// example: "12000"
+	To string `json:"to"`
 }
 
 // CooldownRange представляет диапазон охлаждения.
@@ -46,50 +196,70 @@ type CooldownRange struct {
 	// Min — минимальное значение
 	// example: 1
 	Min float64 `json:"min"`
-	// Max — максимальное значение
+	// Max — максимальное значение включительно; не учитывается, если Unbounded
 	// example: 10000
 	Max float64 `json:"max"`
+	// Unbounded — «потолка нет»: диапазон открыт сверху, допустим только у верхнего диапазона
+	// example: false
+	Unbounded bool `json:"unbounded"`
 	// Period — период охлаждения в днях
 	// example: 7
 	Period int `json:"period"`
 }
 
+// CategoryCooling представляет ограничение охлаждения для категории.
+// @Description Охлаждение для категории не короче MinDays и не длиннее MaxDays.
+type CategoryCooling struct {
+	// Category — категория (без учета регистра)
+	// example: "Электроника"
+	Category string `json:"category" validate:"maxlen=100"`
+	// MinDays — минимальное охлаждение в днях; 0 — без ограничения
+	// example: 14
+	MinDays int `json:"minDays"`
+	// MaxDays — максимальное охлаждение в днях; 0 — без ограничения
+	// example: 0
+	MaxDays int `json:"maxDays"`
+}
+
 // Settings представляет настройки пользователя.
 // @Description Настройки пользователя.
 type Settings struct {
 	// Cooldowns — диапазоны охлаждения
-	Cooldowns []CooldownRange `json:"cooldowns"`
+	Cooldowns []CooldownRange `json:"cooldowns" validate:"maxlen=50"`
+	// CategoryCooling — ограничения охлаждения для категорий поверх диапазонов цены
+	CategoryCooling []CategoryCooling `json:"categoryCooling" validate:"maxlen=100"`
 	// NotificationFreq — частота уведомлений
 	// example: "еженедельно"
-	NotificationFreq string `json:"notificationFrequency"`
+	NotificationFreq string `json:"notificationFrequency" validate:"maxlen=100"`
 	// ExcludedProducts — исключённые продукты
 	// example: "алкоголь"
 	ExcludedProducts string `json:"excludedProducts"`
 	// NotificationChannel — канал уведомлений
 	// example: "email"
-	NotificationChannel string `json:"notificationChannel"`
+	NotificationChannel string `json:"notificationChannel" validate:"oneof=notifications telegram email"`
 	// TotalSpent — потрачено всего
 	// example: 1500
-	TotalSpent float64 `json:"totalSpent"`
+	TotalSpent float64 `json:"totalSpent" validate:"gte=0"`
 	// TotalPurchases — всего покупок
 	// example: 25
-	TotalPurchases int `json:"totalPurchases"`
+	TotalPurchases int `json:"totalPurchases" validate:"gte=0"`
 	// MonthlySaving — ежемесячая сумма
 	// example: 300
-	MonthlySaving float64 `json:"monthlySaving"`
-	// telegramToken — токен Telegram бота
+	MonthlySaving float64 `json:"monthlySaving" validate:"gte=0"`
+	// telegramToken — токен Telegram бота; только для записи, хранится зашифрованным,
+	// в ответах — "***"; "***" в запросе оставляет прежнее значение
 	// example: "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11"
 	TelegramToken string `json:"telegramToken"`
 	// telegramChatId — ID чата Telegram
 	// example: "-1001234567890"
-	TelegramChatID string `json:"telegramChatId"`
+	TelegramChatID string `json:"telegramChatId" validate:"maxlen=64"`
 	// Email — email пользователя
 	// example: test@gmail.com
-	Email string `json:"email"`
+	Email string `json:"email" validate:"email,maxlen=254"`
 	// SMTPEmail — email для SMTP
 	// example: twish@twish.com
-	SMTPEmail string `json:"smtpEmail"`
-	// SMTPPassword — пароль для SMTP
+	SMTPEmail string `json:"smtpEmail" validate:"email,maxlen=254"`
+	// SMTPPassword — пароль для SMTP; только для записи, как telegramToken
 	// example: "s3cr3tP@ssw0rd"
 	SMTPPassword string `json:"smtpPassword"`
 }
@@ -98,19 +268,22 @@ type UserProfile struct {
 	// Nick — никнейм пользователя
 	// example: "TestMeowUser"
 	Nick string `json:"nick"`
+	// Currency — базовая валюта (ISO 4217), в ней считаются охлаждение и комфорт
+	// example: "RUB"
+	Currency string `json:"currency" validate:"maxlen=3"`
 	// Salary — зарплата пользователя
 	// example: 50000
-	Salary float64 `json:"salary"`
+	Salary float64 `json:"salary" validate:"gte=0"`
 	// TotalSavingsProfile — текущие сбережения
 	// example: 20000
-	TotalSavingsProfile float64 `json:"totalSavingsProfile"`
+	TotalSavingsProfile float64 `json:"totalSavingsProfile" validate:"gte=0"`
 	// MonthlySavingProfile — ежемесячные сбережения
 	// example: 5000
-	MonthlySavingProfile float64 `json:"monthlySavingProfile"`
+	MonthlySavingProfile float64 `json:"monthlySavingProfile" validate:"gte=0"`
 	// ComfortPercent — процент комфорта
 	// example: 0.5 == 50%
-	ComfortPercent float64 `json:"comfortPercent"`
+	ComfortPercent float64 `json:"comfortPercent" validate:"gte=0,lte=1"`
 	// BlockedCategories — заблокированные категории
 	// example: ["алкоголь"]
-	BlockedCategories []string `json:"blockedCategories"`
+	BlockedCategories []string `json:"blockedCategories" validate:"maxlen=50,itemmaxlen=100"`
 }
@@ -1,20 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
 )
 
 // Notification представляет структуру уведомления
 type Notification struct {
-	Title   string `json:"title"`
-	Message string `json:"message"`
-	Type    string `json:"type"`
+	Title   string `json:"title" validate:"required,maxlen=200"`
+	Message string `json:"message" validate:"maxlen=2000"`
+	Type    string `json:"type" validate:"maxlen=50"`
 }
 
-// notifyHandler обрабатывает входящие уведомления
+// NotifyHandler создает обработчик входящих уведомлений
 // @Summary Отправить уведомление пользователю
 // @Description Отправляет уведомление пользователю по его ID
 // @Tags notify
@@ -23,45 +28,167 @@ type Notification struct {
 // @Param userId path string true "ID пользователя"
 // @Param notification body Notification true "Объект уведомления"
 // @Success 200 {object} map[string]string
-// @Router /api/notify/{userId} [post]
-func notifyHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Path[len("/api/notify/"):]
+// @Failure 400 {object} Problem "validation_failed"
+// @Security SessionToken
+// @Router /notify/{userId} [post]
+func NotifyHandler(notifier *Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := mux.Vars(r)["userId"]
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+		var notif Notification
+		if !decodeJSON(w, r, &notif) {
+			return
+		}
+
+		notifier.Dispatch(r.Context(), userID, notif)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "ok",
+		})
 	}
+}
 
-	var notif Notification
-	if err := json.NewDecoder(r.Body).Decode(&notif); err != nil {
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
+// Notifier рассылает уведомления. Токен бота и почтовый ящик берутся из настроек
+// пользователя, а если он их не задал — из конфигурации сервера.
+// Отправка идет из очереди в Run, чтобы обработчики и фоновые задачи не ждали внешние сервисы.
+type Notifier struct {
+	storage  *Storage
+	appURL   string
+	smtp     SMTPConfig
+	telegram TelegramConfig
+	queue    chan queuedNotification
+}
+
+// notifyQueueSize — сколько уведомлений может ждать отправки
+const notifyQueueSize = 256
+
+// queuedNotification — уведомление в очереди; requestID связывает отправку с запросом или проходом,
+// который ее вызвал
+type queuedNotification struct {
+	requestID string
+	userID    string
+	notif     Notification
+}
+
+// ctx возвращает контекст отправки с ID исходного запроса
+func (q queuedNotification) ctx() context.Context {
+	return WithRequestID(context.Background(), q.requestID)
+}
+
+// notifyLog — логгер уведомлений
+func notifyLog() *slog.Logger { return logger("notify") }
+
+// NewNotifier создает рассыльщик с адресом приложения и каналами по умолчанию из cfg
+func NewNotifier(storage *Storage, cfg Config) *Notifier {
+	return &Notifier{
+		storage:  storage,
+		appURL:   strings.TrimSuffix(cfg.AppURL, "/"),
+		smtp:     cfg.SMTP,
+		telegram: cfg.Telegram,
+		queue:    make(chan queuedNotification, notifyQueueSize),
 	}
+}
 
-	log.Printf("[NOTIFY] User: %s | Type: %s | Title: %s | Message: %s\n", userID, notif.Type, notif.Title, notif.Message)
+// Dispatch ставит уведомление в очередь; если очередь переполнена, отправляет сразу.
+// ID запроса из ctx попадает в логи отправки.
+func (n *Notifier) Dispatch(ctx context.Context, userID string, notif Notification) {
+	q := queuedNotification{requestID: RequestID(ctx), userID: userID, notif: notif}
+	select {
+	case n.queue <- q:
+	default:
+		notifyLog().WarnContext(ctx, "queue is full, sending synchronously")
+		n.send(q)
+	}
+}
 
-	sendTelegram(userID, notif)
-	sendEmail(userID, notif)
-	sendWebNotification(userID, notif)
+// Run отправляет уведомления из очереди, пока не отменен ctx
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-n.queue:
+			n.send(q)
+		}
+	}
+}
+
+// Flush отправляет оставшиеся в очереди уведомления; по истечении ctx возвращает ошибку
+// с числом неотправленных
+func (n *Notifier) Flush(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%d notifications not sent: %w", len(n.queue), err)
+		}
+		select {
+		case q := <-n.queue:
+			n.send(q)
+		default:
+			return nil
+		}
+	}
+}
+
+// errChannelOff — канал не настроен у пользователя и на сервере, уведомление по нему не отправляется
+var errChannelOff = errors.New("channel is not configured")
+
+// send рассылает уведомление по всем каналам
+func (n *Notifier) send(q queuedNotification) {
+	ctx, userID, notif := q.ctx(), q.userID, q.notif
+	notifyLog().InfoContext(ctx, "sending notification", "user_id", userID, "type", notif.Type)
+
+	set, err := n.storage.NotificationSettings(userID)
+	if err != nil {
+		notifyLog().ErrorContext(ctx, "can't read notification settings", "user_id", userID, "error", err)
+		recordDelivery(ctx, userID, "telegram", err)
+		recordDelivery(ctx, userID, "email", err)
+	} else {
+		recordDelivery(ctx, userID, "telegram", n.sendTelegram(ctx, userID, set, notif))
+		recordDelivery(ctx, userID, "email", n.sendEmail(ctx, userID, set, notif))
+	}
+	recordDelivery(ctx, userID, "web", n.sendWebNotification(ctx, userID, notif))
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "ok",
-	})
+// recordDelivery пишет результат отправки в лог и метрики; ненастроенный канал не учитывается
+func recordDelivery(ctx context.Context, userID, channel string, err error) {
+	if errors.Is(err, errChannelOff) {
+		return
+	}
+	if err != nil {
+		notifyLog().ErrorContext(ctx, "notification failed", "user_id", userID, "channel", channel, "error", err)
+	} else {
+		notifyLog().DebugContext(ctx, "notification sent", "user_id", userID, "channel", channel)
+	}
+	metrics.NotificationResult(channel, err)
 }
 
 // Функции отправки уведомлений (заглушки)
 // В реальном приложении здесь будет интеграция с соответствующими сервисами
 // Например, с Telegram API, SMTP сервером и веб-сокетами
 // для веб-уведомлений.
-func sendTelegram(userID string, notif Notification) {
-	fmt.Printf("[TG] %s -> %s: %s\n", userID, notif.Title, notif.Message)
+// Пока отправка только пишется в лог: без адреса получателя и текста уведомления.
+func (n *Notifier) sendTelegram(ctx context.Context, userID string, set Settings, notif Notification) error {
+	token := set.TelegramToken
+	if token == "" {
+		token = n.telegram.BotToken
+	}
+	if token == "" || set.TelegramChatID == "" {
+		return errChannelOff
+	}
+	notifyLog().InfoContext(ctx, "notification delivered", "user_id", userID, "channel", "telegram", "type", notif.Type)
+	return nil
 }
 
-func sendEmail(userID string, notif Notification) {
-	fmt.Printf("[EMAIL] %s -> %s: %s\n", userID, notif.Title, notif.Message)
+func (n *Notifier) sendEmail(ctx context.Context, userID string, set Settings, notif Notification) error {
+	if set.Email == "" || n.smtp.Host == "" {
+		return errChannelOff
+	}
+	notifyLog().InfoContext(ctx, "notification delivered", "user_id", userID, "channel", "email", "type", notif.Type)
+	return nil
 }
 
-func sendWebNotification(userID string, notif Notification) {
-	fmt.Printf("[WEB] %s -> %s: %s (при клике открыть localhost:3000)\n", userID, notif.Title, notif.Message)
+func (n *Notifier) sendWebNotification(ctx context.Context, userID string, notif Notification) error {
+	notifyLog().InfoContext(ctx, "notification delivered", "user_id", userID, "channel", "web", "type", notif.Type, "link", n.appURL+"/cabinet")
+	return nil
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSendLogsWithoutRecipientOrBody(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(newLogger(&buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	storage := NewStorage()
+	storage.SaveSettings(context.Background(), "u1", Settings{Email: "cat@example.com", TelegramChatID: "987654"})
+	cfg := DefaultConfig()
+	cfg.SMTP.Host = "smtp.example.com"
+	cfg.Telegram.BotToken = "123456:bot-token"
+	n := NewNotifier(storage, cfg)
+	n.send(queuedNotification{userID: "u1", notif: Notification{Title: "Цена снизилась", Message: "Было 500, стало 400", Type: "price_drop"}})
+
+	out := buf.String()
+	for _, channel := range []string{"telegram", "email", "web"} {
+		if !strings.Contains(out, `"channel":"`+channel+`"`) {
+			t.Errorf("no log record for channel %s:\n%s", channel, out)
+		}
+	}
+	for _, leak := range []string{"cat@example.com", "987654", "Цена снизилась", "Было 500"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("log contains %q:\n%s", leak, out)
+		}
+	}
+}
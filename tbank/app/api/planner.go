@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// byPriority упорядочивает желания: выше приоритет, раньше желаемая дата, раньше создано
+func byPriority(wishes []Wish) {
+	sort.SliceStable(wishes, func(i, j int) bool {
+		a, b := wishes[i], wishes[j]
+		if a.Priority.Rank() != b.Priority.Rank() {
+			return a.Priority.Rank() > b.Priority.Rank()
+		}
+		if !a.TargetDate.Equal(b.TargetDate) {
+			// без даты — после всех с датой
+			if a.TargetDate.IsZero() || b.TargetDate.IsZero() {
+				return b.TargetDate.IsZero()
+			}
+			return a.TargetDate.Before(b.TargetDate)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// surveyOrder возвращает активные желания в порядке опроса «всё ещё хотите?»:
+// сначала те, у кого охлаждение уже закончилось, внутри групп — по приоритету.
+func surveyOrder(wishes []Wish, now time.Time) []Wish {
+	var due, cooling []Wish
+	for _, w := range wishes {
+		if w.Status != StatusActive {
+			continue
+		}
+		if !coolingEnd(w).After(now) {
+			due = append(due, w)
+		} else {
+			cooling = append(cooling, w)
+		}
+	}
+	byPriority(due)
+	byPriority(cooling)
+	return append(due, cooling...)
+}
+
+// SurveyItem — желание в очереди опроса
+// @Description Желание в очереди опроса.
+type SurveyItem struct {
+	Wish Wish `json:"wish"`
+	// CoolingEnd — дата окончания охлаждения
+	CoolingEnd time.Time `json:"coolingEnd"`
+	// Due — охлаждение закончилось, пора спросить
+	// example: true
+	Due bool `json:"due"`
+}
+
+// PlanItem — строка плана накоплений
+// @Description Когда хватит денег на желание при накоплении по приоритетам.
+type PlanItem struct {
+	// WishID — ID желания
+	WishID string `json:"wishId"`
+	// Title — название желания
+	Title string `json:"title"`
+	// Price — цена в валюте профиля
+	Price float64 `json:"price"`
+	// Currency — валюта профиля
+	// example: "RUB"
+	Currency string `json:"currency"`
+	// Priority — приоритет
+	Priority WishPriority `json:"priority"`
+	// Months — через сколько месяцев хватит денег; -1 — недостижимо
+	// example: 3
+	Months int `json:"months"`
+	// FundedAt — дата, когда хватит денег
+	FundedAt time.Time `json:"fundedAt,omitzero"`
+	// TargetDate — желаемая дата покупки
+	TargetDate time.Time `json:"targetDate,omitzero"`
+	// MeetsTarget — успеваем к желаемой дате
+	MeetsTarget bool `json:"meetsTarget"`
+}
+
+// planSavings распределяет сбережения по активным желаниям в порядке приоритета.
+// Считается так же, как CalculateComfortMonths, но цены складываются: каждое следующее
+// желание ждет, пока накопится и на все предыдущие.
+func planSavings(profile UserProfile, wishes []Wish, now time.Time) []PlanItem {
+	active := make([]Wish, 0, len(wishes))
+	for _, w := range wishes {
+		if w.Status == StatusActive {
+			active = append(active, w)
+		}
+	}
+	byPriority(active)
+
+	remainCoef := 1 - profile.ComfortPercent
+	available := profile.TotalSavingsProfile * remainCoef
+	monthly := profile.MonthlySavingProfile * remainCoef
+
+	plan := make([]PlanItem, 0, len(active))
+	cumulative := 0.0
+	for _, w := range active {
+		cumulative += w.BasePrice()
+		item := PlanItem{
+			WishID:     w.ID,
+			Title:      w.Title,
+			Price:      w.BasePrice(),
+			Currency:   profileCurrency(profile),
+			Priority:   w.Priority,
+			Months:     -1,
+			TargetDate: w.TargetDate,
+		}
+
+		switch left := cumulative - available; {
+		case remainCoef <= 0:
+		case left <= 0:
+			item.Months = 0
+		case monthly > 0:
+			item.Months = int(math.Ceil(left / monthly))
+		}
+
+		if item.Months >= 0 {
+			item.FundedAt = now.AddDate(0, item.Months, 0)
+			item.MeetsTarget = w.TargetDate.IsZero() || !item.FundedAt.After(w.TargetDate)
+		}
+		plan = append(plan, item)
+	}
+	return plan
+}
+
+// GetSurveyHandler создает обработчик очереди опроса
+// @Summary Очередь опроса
+// @Description Активные желания в порядке опроса: сначала с законченным охлаждением, затем по приоритету и желаемой дате
+// @Tags planner
+// @Param userId path string true "ID пользователя"
+// @Produce json
+// @Success 200 {array} SurveyItem
+// @Security SessionToken
+// @Router /survey/{userId} [get]
+func GetSurveyHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		now := time.Now()
+
+		items := []SurveyItem{}
+		for _, wish := range surveyOrder(storage.GetWishes(userId, StatusActive), now) {
+			end := coolingEnd(wish)
+			items = append(items, SurveyItem{Wish: wish, CoolingEnd: end, Due: !end.After(now)})
+		}
+
+		logger("handler").DebugContext(r.Context(), "survey read", "user_id", userId, "wishes", len(items))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	}
+}
+
+// GetPlannerHandler создает обработчик плана накоплений
+// @Summary План накоплений
+// @Description Распределяет сбережения и ежемесячные накопления по активным желаниям в порядке приоритета
+// @Tags planner
+// @Param userId path string true "ID пользователя"
+// @Produce json
+// @Success 200 {array} PlanItem
+// @Security SessionToken
+// @Router /planner/{userId} [get]
+func GetPlannerHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := mux.Vars(r)["userId"]
+		profile, _ := storage.GetProfile(userId)
+		plan := planSavings(profile, storage.GetWishes(userId, StatusActive), time.Now())
+
+		logger("handler").DebugContext(r.Context(), "planner read", "user_id", userId)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+	}
+}
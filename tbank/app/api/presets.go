@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// CooldownPreset — готовый набор диапазонов охлаждения
+// @Description Именованный набор диапазонов охлаждения.
+type CooldownPreset struct {
+	// Name — имя пресета
+	// example: "balanced"
+	Name string `json:"name"`
+	// Title — название для интерфейса
+	// example: "Сбалансированный"
+	Title string `json:"title"`
+	// Currency — валюта границ; при применении они пересчитываются в валюту профиля
+	// example: "RUB"
+	Currency string `json:"currency"`
+	// Cooldowns — диапазоны охлаждения
+	Cooldowns []CooldownRange `json:"cooldowns"`
+}
+
+// presetCurrency — валюта, в которой заданы границы встроенных пресетов
+const presetCurrency = "RUB"
+
+// cooldownPresets — встроенные пресеты; границы в рублях (presetCurrency)
+var cooldownPresets = map[string]CooldownPreset{
+	"strict": {
+		Name:     "strict",
+		Title:    "Строгий",
+		Currency: presetCurrency,
+		Cooldowns: []CooldownRange{
+			{Min: 0, Max: 5000, Period: 1},
+			{Min: 5001, Max: 15000, Period: 3},
+			{Min: 15001, Max: 50000, Period: 14},
+			{Min: 50001, Max: 150000, Period: 30},
+			{Min: 150001, Unbounded: true, Period: 60},
+		},
+	},
+	"balanced": {
+		Name:     "balanced",
+		Title:    "Сбалансированный",
+		Currency: presetCurrency,
+		Cooldowns: []CooldownRange{
+			{Min: 0, Max: 15000, Period: 1},
+			{Min: 15001, Max: 50000, Period: 7},
+			{Min: 50001, Max: 150000, Period: 14},
+			{Min: 150001, Unbounded: true, Period: 30},
+		},
+	},
+	"relaxed": {
+		Name:     "relaxed",
+		Title:    "Мягкий",
+		Currency: presetCurrency,
+		Cooldowns: []CooldownRange{
+			{Min: 0, Max: 30000, Period: 1},
+			{Min: 30001, Max: 100000, Period: 3},
+			{Min: 100001, Max: 300000, Period: 7},
+			{Min: 300001, Unbounded: true, Period: 14},
+		},
+	},
+}
+
+// presetOrder — порядок пресетов в списке: от строгого к мягкому
+var presetOrder = []string{"strict", "balanced", "relaxed"}
+
+// presetCooldowns возвращает диапазоны пресета в валюте currency
+func presetCooldowns(preset CooldownPreset, currency string, rates RateProvider) ([]CooldownRange, error) {
+	return convertCooldowns(preset.Cooldowns, preset.Currency, currency, rates)
+}
+
+// categoryCoolingFor ищет правило охлаждения для категории без учета регистра
+func categoryCoolingFor(category string, settings Settings) (CategoryCooling, bool) {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return CategoryCooling{}, false
+	}
+	for _, c := range settings.CategoryCooling {
+		if strings.EqualFold(strings.TrimSpace(c.Category), category) {
+			return c, true
+		}
+	}
+	return CategoryCooling{}, false
+}
+
+// validateCategoryCooling проверяет правила категорий: категория задана и не повторяется,
+// дни в пределах maxCoolingDays, минимум не больше максимума
+func validateCategoryCooling(rules []CategoryCooling) []FieldError {
+	var errs []FieldError
+	add := func(i int, field, format string, args ...any) {
+		errs = append(errs, FieldError{
+			Field:   fmt.Sprintf("categoryCooling[%d].%s", i, field),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	seen := make(map[string]int, len(rules))
+	for i, c := range rules {
+		key := strings.ToLower(strings.TrimSpace(c.Category))
+		if key == "" {
+			add(i, "category", "is required")
+		} else if j, ok := seen[key]; ok {
+			add(i, "category", "duplicates categoryCooling[%d]", j)
+		} else {
+			seen[key] = i
+		}
+		if c.MinDays < 0 || c.MinDays > maxCoolingDays {
+			add(i, "minDays", "must be between 0 and %d days", maxCoolingDays)
+		}
+		if c.MaxDays < 0 || c.MaxDays > maxCoolingDays {
+			add(i, "maxDays", "must be between 0 and %d days", maxCoolingDays)
+		}
+		if c.MinDays == 0 && c.MaxDays == 0 {
+			add(i, "minDays", "minDays or maxDays must be set")
+		}
+		if c.MaxDays > 0 && c.MinDays > c.MaxDays {
+			add(i, "maxDays", "must be greater than or equal to minDays")
+		}
+	}
+	return errs
+}
+
+// GetCooldownPresetsHandler создает обработчик списка пресетов охлаждения
+// @Summary Пресеты охлаждения
+// @Description Возвращает встроенные наборы диапазонов охлаждения
+// @Tags settings
+// @Produce json
+// @Success 200 {array} CooldownPreset
+// @Router /cooldown-presets [get]
+func GetCooldownPresetsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := make([]CooldownPreset, 0, len(cooldownPresets))
+		for _, name := range presetOrder {
+			list = append(list, cooldownPresets[name])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// ApplyCooldownPresetHandler создает обработчик применения пресета к настройкам
+// @Summary Применить пресет охлаждения
+// @Description Заменяет диапазоны охлаждения пользователя пресетом, пересчитав границы в валюту профиля; правила категорий сохраняются, охлаждение активных желаний пересчитывается
+// @Tags settings
+// @Param userId path string true "ID пользователя"
+// @Param name path string true "strict, balanced или relaxed"
+// @Produce json
+// @Success 200 {object} Settings
+// @Failure 404 {object} Problem "unknown preset"
+// @Security SessionToken
+// @Router /settings/{userId}/cooldowns/preset/{name} [post]
+func ApplyCooldownPresetHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userId, name := vars["userId"], strings.ToLower(vars["name"])
+
+		preset, ok := cooldownPresets[name]
+		if !ok {
+			names := append([]string(nil), presetOrder...)
+			sort.Strings(names)
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "unknown preset, expected one of: "+strings.Join(names, ", "))
+			return
+		}
+
+		profile, _ := storage.GetProfile(userId)
+		cooldowns, err := presetCooldowns(preset, profileCurrency(profile), storage.Rates())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		set, err := storage.UpdateSettings(r.Context(), userId, func(set Settings) (Settings, error) {
+			set.Cooldowns = cooldowns
+			return set, nil
+		})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "cooldown preset applied", "user_id", userId, "preset", name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(maskSettings(set))
+	}
+}
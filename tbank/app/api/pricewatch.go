@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// priceDropThreshold — относительное снижение цены, о котором стоит уведомить
+const priceDropThreshold = 0.10
+
+// maxPriceHistory — сколько точек истории цены хранится на одно желание
+const maxPriceHistory = 500
+
+// PricePoint — наблюдение цены товара в валюте желания
+// @Description Цена товара в момент проверки.
+type PricePoint struct {
+	// At — дата и время проверки
+	// example: "2024-01-02T10:00:00Z"
+	At time.Time `json:"at"`
+	// Price — цена
+	// example: 9500
+	Price float64 `json:"price"`
+}
+
+// fetchTimeout — сколько ждать цену по одной ссылке
+const fetchTimeout = 15 * time.Second
+
+// PriceFetcher получает текущую цену товара по ссылке
+type PriceFetcher interface {
+	FetchPrice(ctx context.Context, url string) (float64, error)
+}
+
+// errPriceNotFound — на странице не удалось найти цену
+var errPriceNotFound = errors.New("price not found on page")
+
+// HTTPPriceFetcher ищет цену в HTML страницы товара: микроразметка, OpenGraph, JSON-LD
+type HTTPPriceFetcher struct {
+	Client *http.Client
+	// MaxBody — сколько байт страницы читать
+	MaxBody int64
+}
+
+// NewHTTPPriceFetcher создает HTTP-фетчер с разумными таймаутами.
+// Ссылки задают пользователи, поэтому клиент ходит только на публичные адреса
+// и порты 80/443 и проверяет каждый редирект.
+func NewHTTPPriceFetcher() *HTTPPriceFetcher {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: checkDialAddress}
+	return &HTTPPriceFetcher{
+		Client: &http.Client{
+			Timeout: fetchTimeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     30 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxFetchRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+				}
+				return checkFetchURL(req.URL)
+			},
+		},
+		MaxBody: 2 << 20,
+	}
+}
+
+// maxFetchRedirects — сколько редиректов фетчер проходит до страницы товара
+const maxFetchRedirects = 5
+
+// errForbiddenTarget — ссылка ведет на внутренний адрес или нестандартный порт
+var errForbiddenTarget = errors.New("price fetch target is not allowed")
+
+// checkFetchURL проверяет ссылку до соединения: схему, порт и адрес, если он указан явно.
+// Имена хостов проверяет checkDialAddress после разрешения DNS.
+func checkFetchURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", errForbiddenTarget, u.Scheme)
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return fmt.Errorf("%w: port %s", errForbiddenTarget, port)
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !isPublicAddr(ip) {
+		return fmt.Errorf("%w: address %s", errForbiddenTarget, ip)
+	}
+	return nil
+}
+
+// checkDialAddress — хук net.Dialer.Control: вызывается для уже разрешенного адреса
+// перед каждым соединением и пропускает только публичные IP на портах 80 и 443
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if port != "80" && port != "443" {
+		return fmt.Errorf("%w: port %s", errForbiddenTarget, port)
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: address %s", errForbiddenTarget, host)
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("%w: address %s", errForbiddenTarget, ip)
+	}
+	return nil
+}
+
+// nonPublicPrefixes — служебные сети, которые не покрывают методы netip.Addr:
+// «эта сеть», CGNAT и сеть для тестов производительности
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// isPublicAddr отсекает loopback, частные, link-local, multicast, неуказанные адреса
+// и nonPublicPrefixes
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+var pricePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)itemprop=["']price["'][^>]*content=["']([^"']+)["']`),
+	regexp.MustCompile(`(?i)content=["']([^"']+)["'][^>]*itemprop=["']price["']`),
+	regexp.MustCompile(`(?i)property=["'](?:product|og):price:amount["'][^>]*content=["']([^"']+)["']`),
+	regexp.MustCompile(`(?i)"price"\s*:\s*"?([0-9][0-9\s.,]*)`),
+}
+
+// parsePrice разбирает цену в распространенных форматах: «12 990,50», «12990.50»,
+// «12,990.50», «1.299,00». Десятичным разделителем считается последняя точка или запятая;
+// если вид разделителя только один и он повторяется или за ним ровно три цифры, это разделитель тысяч.
+func parsePrice(raw string) (float64, error) {
+	raw = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u202f', '\t', '\n', '\'':
+			return -1
+		}
+		return r
+	}, raw)
+	raw = strings.TrimRight(raw, ".,")
+
+	dec := strings.LastIndexAny(raw, ".,")
+	if dec >= 0 {
+		sep, other := raw[dec:dec+1], ","
+		if sep == "," {
+			other = "."
+		}
+		if !strings.Contains(raw, other) && (strings.Count(raw, sep) > 1 || len(raw)-dec-1 == 3) {
+			dec = -1
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case i == dec:
+			b.WriteByte('.')
+		case c == '.' || c == ',':
+		default:
+			b.WriteByte(c)
+		}
+	}
+	p, err := strconv.ParseFloat(b.String(), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsInf(p, 0) || math.IsNaN(p) {
+		return 0, errPriceNotFound
+	}
+	return p, nil
+}
+
+// FetchPrice загружает страницу и возвращает первую найденную цену
+func (f *HTTPPriceFetcher) FetchPrice(ctx context.Context, link string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkFetchURL(req.URL); err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "TWishPriceWatch/1.0")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBody))
+	if err != nil {
+		return 0, err
+	}
+	for _, re := range pricePatterns {
+		if m := re.FindSubmatch(body); m != nil {
+			if p, err := parsePrice(string(m[1])); err == nil && p > 0 {
+				return p, nil
+			}
+		}
+	}
+	return 0, errPriceNotFound
+}
+
+// PriceWatcher периодически перепроверяет цены товаров по ссылкам активных желаний
+type PriceWatcher struct {
+	storage  *Storage
+	fetcher  PriceFetcher
+	interval time.Duration
+	notify   func(ctx context.Context, userID string, notif Notification)
+	// lastRun — начало последнего прохода, UnixNano; 0 — еще не запускался
+	lastRun atomic.Int64
+	// heartbeat — последний шаг цикла (начало прохода или проверка ссылки), UnixNano
+	heartbeat atomic.Int64
+}
+
+// NewPriceWatcher создает наблюдатель цен, который сообщает об изменениях через notify
+func NewPriceWatcher(storage *Storage, fetcher PriceFetcher, interval time.Duration, notify func(ctx context.Context, userID string, notif Notification)) *PriceWatcher {
+	return &PriceWatcher{
+		storage:  storage,
+		fetcher:  fetcher,
+		interval: interval,
+		notify:   notify,
+	}
+}
+
+// Run проверяет цены раз в interval, пока не отменен ctx
+func (pw *PriceWatcher) Run(ctx context.Context) {
+	if pw.interval <= 0 {
+		logger("pricewatch").Info("disabled")
+		return
+	}
+	logger("pricewatch").Info("started", "interval", pw.interval.String())
+	metrics.RegisterScheduler("price_watch", pw.Lag)
+	ticker := time.NewTicker(pw.interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UnixNano()
+		pw.lastRun.Store(now)
+		pw.heartbeat.Store(now)
+		// у каждого прохода свой ID, по нему связываются записи хранилища и уведомлений
+		pw.CheckAll(WithRequestID(ctx, "pricewatch-"+newRequestID()))
+		select {
+		case <-ctx.Done():
+			logger("pricewatch").Info("stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Lag возвращает, на сколько очередной проход опаздывает относительно расписания
+func (pw *PriceWatcher) Lag(now time.Time) time.Duration {
+	last := pw.lastRun.Load()
+	if pw.interval <= 0 || last == 0 {
+		return 0
+	}
+	return max(now.Sub(time.Unix(0, last))-pw.interval, 0)
+}
+
+// Alive сообщает, работает ли цикл наблюдателя; выключенный считается живым.
+// Долгий проход по медленным ссылкам — не отказ: он виден в метрике опоздания,
+// а здесь проверяется только, что цикл движется: между шагами цикла проходит
+// не больше таймаута одной ссылки и интервала ожидания, запас — еще один таймаут.
+func (pw *PriceWatcher) Alive(ctx context.Context) error {
+	if pw.interval <= 0 {
+		return nil
+	}
+	beat := pw.heartbeat.Load()
+	if beat == 0 {
+		return errors.New("price watcher has not started")
+	}
+	if idle := time.Since(time.Unix(0, beat)); idle > pw.interval+2*fetchTimeout {
+		return fmt.Errorf("price watcher loop is stuck for %s", idle.Round(time.Second))
+	}
+	return nil
+}
+
+// CheckAll перепроверяет цены всех отслеживаемых желаний
+func (pw *PriceWatcher) CheckAll(ctx context.Context) {
+	for _, ww := range pw.storage.WatchedWishes() {
+		if ctx.Err() != nil {
+			return
+		}
+		pw.heartbeat.Store(time.Now().UnixNano())
+		if err := pw.checkWithTimeout(ctx, ww.UserID, ww.Wish); err != nil {
+			logger("pricewatch").WarnContext(ctx, "price check failed", "user_id", ww.UserID, "wish_id", ww.Wish.ID, "error", err)
+		}
+	}
+}
+
+// checkWithTimeout ограничивает проверку одной ссылки fetchTimeout, чтобы фетчер
+// без своего таймаута не останавливал цикл
+func (pw *PriceWatcher) checkWithTimeout(ctx context.Context, userId string, wish Wish) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	return pw.check(ctx, userId, wish)
+}
+
+// check получает цену, пишет ее в историю и при изменении обновляет желание.
+// Охлаждение пересчитывается через repriceWish и меняется только при переходе
+// через границу CooldownRange.
+func (pw *PriceWatcher) check(ctx context.Context, userId string, wish Wish) error {
+	price, err := pw.fetcher.FetchPrice(ctx, wish.URL)
+	if err != nil {
+		return err
+	}
+
+	pw.storage.RecordPrice(userId, wish.ID, PricePoint{At: time.Now(), Price: price})
+	if price == wish.Price {
+		return nil
+	}
+
+	settings := pw.storage.GetSettings(userId)
+	profile, _ := pw.storage.GetProfile(userId)
+	rates := pw.storage.Rates()
+	updated, err := pw.storage.RepriceWish(ctx, userId, wish.ID, wish.URL, func(w *Wish) error {
+		return repriceWish(w, price, settings, profile, rates)
+	})
+	if errors.Is(err, errWishChanged) {
+		logger("pricewatch").DebugContext(ctx, "wish changed during price check", "user_id", userId, "wish_id", wish.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logger("pricewatch").InfoContext(ctx, "price changed", "user_id", userId, "wish_id", wish.ID,
+		"old_price", wish.Price, "new_price", price, "old_cooling_days", wish.RecommendedCooling, "new_cooling_days", updated.RecommendedCooling)
+
+	if drop := (wish.Price - price) / wish.Price; drop >= priceDropThreshold {
+		pw.notify(ctx, userId, Notification{
+			Title:   "Цена снизилась: " + wish.Title,
+			Message: fmt.Sprintf("Было %s, стало %s (−%.0f%%)", formatMoney(wish.Price, wish.Currency), formatMoney(price, wish.Currency), drop*100),
+			Type:    "price_drop",
+		})
+	}
+	return nil
+}
+
+// repriceWish ставит желанию цену из магазина и пересчитывает охлаждение и комфорт
+func repriceWish(w *Wish, price float64, settings Settings, profile UserProfile, rates RateProvider) error {
+	next := *w
+	next.Price = price
+	if err := convertWish(&next, profileCurrency(profile), rates); err != nil {
+		return err
+	}
+	recalcWish(&next, *w, settings, profile, time.Now())
+	*w = next
+	return nil
+}
+
+// GetPriceHistoryHandler создает обработчик истории цены желания
+// @Summary История цены
+// @Description Возвращает наблюдения цены товара по ссылке желания
+// @Tags wishes
+// @Param userId path string true "ID пользователя"
+// @Param wishId path string true "ID желания"
+// @Produce json
+// @Success 200 {array} PricePoint
+// @Failure 404 {object} Problem "not found"
+// @Security SessionToken
+// @Router /wishes/{userId}/{wishId}/prices [get]
+func GetPriceHistoryHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		history, ok := storage.PriceHistory(vars["userId"], vars["wishId"])
+		if !ok {
+			writeError(w, r, errWishNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want float64
+	}{
+		{"12990", 12990},
+		{"12990.50", 12990.5},
+		{"12 990,50", 12990.5},
+		{"12 990", 12990},
+		{"12,990.50", 12990.5},
+		{"1.299,00", 1299},
+		{"1,299,000", 1299000},
+		{"1.299.000", 1299000},
+		{"12,990", 12990},
+		{"99,9", 99.9},
+		{"12990, ", 12990},
+	}
+	for _, tt := range tests {
+		got, err := parsePrice(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("parsePrice(%q) = %v, %v; want %v", tt.raw, got, err, tt.want)
+		}
+	}
+	if _, err := parsePrice("Inf"); err == nil {
+		t.Error("parsePrice(\"Inf\") accepted an infinite price")
+	}
+}
+
+func TestCheckDialAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"93.184.216.34:80", true},
+		{"93.184.216.34:8080", false},
+		{"127.0.0.1:80", false},
+		{"10.0.0.5:443", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:443", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[fe80::1]:80", false},
+		{"0.1.2.3:80", false},
+		{"100.64.0.1:443", false},
+		{"100.127.255.254:443", false},
+		{"100.128.0.1:443", true},
+		{"198.18.0.1:80", false},
+		{"198.19.255.255:80", false},
+		{"198.20.0.1:80", true},
+		{"[::ffff:100.64.0.1]:443", false},
+	}
+	for _, tt := range tests {
+		err := checkDialAddress("tcp", tt.address, nil)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkDialAddress(%q) = %v, allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
+
+func TestFetchPriceRejectsInternalTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<meta itemprop="price" content="100">`))
+	}))
+	defer srv.Close()
+
+	f := NewHTTPPriceFetcher()
+	for _, link := range []string{srv.URL, "http://127.0.0.1/", "http://localhost/", "ftp://example.com/"} {
+		if _, err := f.FetchPrice(context.Background(), link); !errors.Is(err, errForbiddenTarget) {
+			t.Errorf("FetchPrice(%q) error = %v, want %v", link, err, errForbiddenTarget)
+		}
+	}
+}
+
+func TestFetchPriceChecksRedirects(t *testing.T) {
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	f := NewHTTPPriceFetcher()
+	// тестовый сервер слушает loopback, поэтому первый хоп пропускаем
+	f.Client.Transport = http.DefaultTransport
+	if _, err := f.FetchPrice(context.Background(), redirect.URL); !errors.Is(err, errForbiddenTarget) {
+		t.Errorf("FetchPrice() followed a redirect to an internal address: %v", err)
+	}
+}
+
+func TestRepriceWishKeepsEditHistory(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Наушники", Price: 10000, URL: "https://shop.example/1"})
+	before := storage.GetWishes("u1", StatusActive)[0]
+	rates := storage.Rates()
+
+	got, err := storage.RepriceWish(ctx, "u1", "w1", "https://shop.example/1", func(w *Wish) error {
+		return repriceWish(w, 8000, Settings{}, UserProfile{}, rates)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Price != 8000 || got.ConvertedPrice != 8000 {
+		t.Errorf("price = %v (converted %v), want 8000", got.Price, got.ConvertedPrice)
+	}
+	if len(got.Edits) != 0 || !got.UpdateAt.Equal(before.UpdateAt) {
+		t.Errorf("repricing touched edit history: edits %d, updateAt %v -> %v", len(got.Edits), before.UpdateAt, got.UpdateAt)
+	}
+}
+
+func TestRepriceWishSkipsChangedWish(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Наушники", Price: 10000, URL: "https://shop.example/1"})
+	storage.AddWish(ctx, "u1", Wish{ID: "w2", Title: "Колонка", Price: 5000, URL: "https://shop.example/2"})
+	if _, err := storage.UpdateWishStatus(ctx, "u1", "w2", StatusCompleted); err != nil {
+		t.Fatal(err)
+	}
+	reprice := func(w *Wish) error {
+		w.Price = 1
+		return nil
+	}
+
+	if _, err := storage.RepriceWish(ctx, "u1", "w1", "https://shop.example/old", reprice); !errors.Is(err, errWishChanged) {
+		t.Errorf("wish with another URL: error = %v, want errWishChanged", err)
+	}
+	if _, err := storage.RepriceWish(ctx, "u1", "w2", "https://shop.example/2", reprice); !errors.Is(err, errWishChanged) {
+		t.Errorf("completed wish: error = %v, want errWishChanged", err)
+	}
+	if p := storage.GetWishes("u1", StatusActive)[0].Price; p != 10000 {
+		t.Errorf("price = %v, want unchanged 10000", p)
+	}
+}
+
+func TestPriceWatcherAlive(t *testing.T) {
+	pw := NewPriceWatcher(NewStorage(), nil, time.Hour, nil)
+	if err := pw.Alive(context.Background()); err == nil {
+		t.Error("watcher that has not started is alive")
+	}
+
+	// проход идет три часа по медленным ссылкам, но цикл движется
+	pw.lastRun.Store(time.Now().Add(-3 * time.Hour).UnixNano())
+	pw.heartbeat.Store(time.Now().Add(-fetchTimeout).UnixNano())
+	if err := pw.Alive(context.Background()); err != nil {
+		t.Errorf("slow pass makes the watcher dead: %v", err)
+	}
+	if lag := pw.Lag(time.Now()); lag < 2*time.Hour {
+		t.Errorf("lag = %s, want the slow pass in the metric", lag)
+	}
+
+	pw.heartbeat.Store(time.Now().Add(-2 * time.Hour).UnixNano())
+	if err := pw.Alive(context.Background()); err == nil {
+		t.Error("stuck loop is alive")
+	}
+
+	if err := NewPriceWatcher(NewStorage(), nil, 0, nil).Alive(context.Background()); err != nil {
+		t.Errorf("disabled watcher: %v", err)
+	}
+}
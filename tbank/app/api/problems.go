@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// problemContentType — тип ответа с ошибкой по RFC 7807
+const problemContentType = "application/problem+json"
+
+// requestIDHeader — заголовок с ID запроса; приходящий от клиента сохраняется
+const requestIDHeader = "X-Request-ID"
+
+// Машиночитаемые коды ошибок API
+const (
+	codeBadRequest        = "bad_request"
+	codeInvalidJSON       = "invalid_json"
+	codeValidation        = "validation_failed"
+	codeNotFound          = "not_found"
+	codeUserNotFound      = "user_not_found"
+	codeWishNotFound      = "wish_not_found"
+	codeVersionNotFound   = "version_not_found"
+	codeConflict          = "conflict"
+	codeIllegalTransition = "illegal_transition"
+	codeUnauthorized      = "unauthorized"
+	codeForbidden         = "forbidden"
+	codeRateLimited       = "too_many_requests"
+	codeTooLarge          = "payload_too_large"
+	codeUnsupportedMedia  = "unsupported_media_type"
+	codeMethodNotAllowed  = "method_not_allowed"
+	codeInternal          = "internal_error"
+)
+
+// Problem — описание ошибки по RFC 7807
+// @Description Ошибка API (application/problem+json).
+type Problem struct {
+	// Type — ссылка на описание типа ошибки
+	// example: "/errors/validation_failed"
+	Type string `json:"type"`
+	// Title — краткое описание типа ошибки
+	// example: "Bad Request"
+	Title string `json:"title"`
+	// Status — HTTP-статус
+	// example: 400
+	Status int `json:"status"`
+	// Detail — описание конкретного случая
+	// example: "title is required"
+	Detail string `json:"detail,omitempty"`
+	// Instance — путь запроса
+	// example: "/api/v1/wishes/TestMeowUser"
+	Instance string `json:"instance,omitempty"`
+	// Code — машиночитаемый код ошибки
+	// example: "validation_failed"
+	Code string `json:"code"`
+	// RequestID — ID запроса, он же в заголовке X-Request-ID
+	// example: "5f0c1e9a7b3d4c2e"
+	RequestID string `json:"requestId,omitempty"`
+	// Errors — ошибки по полям
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// errorProblem — как ошибка хранилища или валидации отображается в ответ
+type errorProblem struct {
+	err    error
+	status int
+	code   string
+	// field — поле запроса, к которому относится ошибка
+	field string
+}
+
+// errorProblems — известные ошибки; первое совпадение по errors.Is побеждает
+var errorProblems = []errorProblem{
+	{errWishNotFound, http.StatusNotFound, codeWishNotFound, ""},
+	{errUserNotFound, http.StatusNotFound, codeUserNotFound, ""},
+	{errVersionNotFound, http.StatusNotFound, codeVersionNotFound, ""},
+	{errIllegalTransition, http.StatusConflict, codeIllegalTransition, ""},
+	{errMergeInactive, http.StatusConflict, codeConflict, "duplicateIds"},
+	{errMergeInvalid, http.StatusUnprocessableEntity, codeValidation, ""},
+	{errNickTaken, http.StatusConflict, codeConflict, "nick"},
+	{errPhoneTaken, http.StatusConflict, codeConflict, "phone"},
+	{errNoSession, http.StatusUnauthorized, codeUnauthorized, ""},
+	{errInvalidSession, http.StatusUnauthorized, codeUnauthorized, ""},
+	{errPINRequired, http.StatusUnauthorized, codeUnauthorized, "pin"},
+	{errWrongPIN, http.StatusUnauthorized, codeUnauthorized, "pin"},
+	{errForeignUser, http.StatusForbidden, codeForbidden, ""},
+	{errPINLocked, http.StatusTooManyRequests, codeRateLimited, ""},
+
+	{errInvalidNick, http.StatusBadRequest, codeValidation, "nick"},
+	{errInvalidPhone, http.StatusBadRequest, codeValidation, "phone"},
+	{errInvalidPIN, http.StatusBadRequest, codeValidation, "pin"},
+	{errEmptyTitle, http.StatusBadRequest, codeValidation, "title"},
+	{errLongTitle, http.StatusBadRequest, codeValidation, "title"},
+	{errInvalidPrice, http.StatusBadRequest, codeValidation, "price"},
+	{errLongCategory, http.StatusBadRequest, codeValidation, "category"},
+	{errBlockedCategory, http.StatusBadRequest, codeValidation, "category"},
+	{errLongNotes, http.StatusBadRequest, codeValidation, "notes"},
+	{errInvalidURL, http.StatusBadRequest, codeValidation, "url"},
+	{errTooManyTags, http.StatusBadRequest, codeValidation, "tags"},
+	{errLongTag, http.StatusBadRequest, codeValidation, "tags"},
+	{errInvalidPriority, http.StatusBadRequest, codeValidation, "priority"},
+	{errInvalidTarget, http.StatusBadRequest, codeValidation, "targetDate"},
+	{errUnknownCurrency, http.StatusBadRequest, codeValidation, "currency"},
+	{errInvalidCursor, http.StatusBadRequest, codeValidation, "cursor"},
+	{errMergeSelf, http.StatusBadRequest, codeValidation, "duplicateIds"},
+	{errPatchNotObject, http.StatusBadRequest, codeInvalidJSON, ""},
+	{errBadRequest, http.StatusBadRequest, codeBadRequest, ""},
+}
+
+// errBadRequest — ошибка в данных запроса, для которой нет своей ошибки в errorProblems
+var errBadRequest = errors.New("bad request")
+
+// badRequestError сохраняет текст исходной ошибки и совпадает с errBadRequest по errors.Is
+type badRequestError struct{ error }
+
+func (e badRequestError) Is(target error) bool { return target == errBadRequest }
+
+func (e badRequestError) Unwrap() error { return e.error }
+
+// badRequest помечает err как ошибку в данных запроса: writeError ответит 400 с ее текстом
+func badRequest(err error) error {
+	return badRequestError{err}
+}
+
+type requestIDKey struct{}
+
+// requestIDPattern — допустимый ID запроса от клиента
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// newRequestID создает случайный ID запроса
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware присваивает запросу ID: берет X-Request-ID клиента или создает новый,
+// кладет его в контекст и возвращает в заголовке ответа
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// WithRequestID возвращает контекст с ID запроса; так ID доходит до фоновой отправки уведомлений
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает ID запроса из контекста
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newProblem собирает ответ об ошибке для запроса
+func newProblem(r *http.Request, status int, code, detail string) Problem {
+	return Problem{
+		Type:      "/errors/" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: RequestID(r.Context()),
+	}
+}
+
+// writeProblemBody отправляет готовый Problem
+func writeProblemBody(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeProblem отвечает ошибкой с кодом и описанием
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblemBody(w, newProblem(r, status, code, detail))
+}
+
+// writeFieldErrors отвечает 400 со списком ошибок по полям на языке из Accept-Language
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, errs []FieldError) {
+	lang := requestLang(r)
+	p := newProblem(r, http.StatusBadRequest, codeValidation, localize(lang, "invalid_fields", "", "request has invalid fields"))
+	p.Errors = localizeFieldErrors(lang, errs)
+	writeProblemBody(w, p)
+}
+
+// writeError отвечает на ошибку хранилища или валидации: известные ошибки получают
+// свой статус и код, ошибки по полям — список полей, слишком большое тело — 413.
+// Остальные — внутренние: клиент получает 500 без подробностей, ошибка пишется в лог
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var fe fieldErrors
+	if errors.As(err, &fe) {
+		writeFieldErrors(w, r, fe)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit))
+		return
+	}
+	for _, ep := range errorProblems {
+		if !errors.Is(err, ep.err) {
+			continue
+		}
+		p := newProblem(r, ep.status, ep.code, err.Error())
+		if ep.field != "" {
+			p.Errors = []FieldError{{Field: ep.field, Message: err.Error()}}
+		}
+		writeProblemBody(w, p)
+		return
+	}
+	logger("handler").ErrorContext(r.Context(), "request failed", "method", r.Method, "route", routeTemplate(r), "error", err)
+	writeProblem(w, r, http.StatusInternalServerError, codeInternal, "internal server error")
+}
+
+// notFoundHandler отвечает 404 на неизвестный маршрут
+func notFoundHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "no route for "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// methodNotAllowedHandler отвечает 405 на неподдерживаемый метод
+func methodNotAllowedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method "+r.Method+" is not allowed for "+r.URL.Path)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"known", errWishNotFound, http.StatusNotFound, codeWishNotFound, "not found"},
+		{"bad request", badRequest(errors.New("unknown sort field")), http.StatusBadRequest, codeBadRequest, "unknown sort field"},
+		{"internal", errors.New("disk is on fire at /var/lib/twish"), http.StatusInternalServerError, codeInternal, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, httptest.NewRequest("GET", "/api/v1/wishes/cat", nil), tt.err)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var p Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.code || p.Detail != tt.detail {
+				t.Errorf("problem = %q %q, want %q %q", p.Code, p.Detail, tt.code, tt.detail)
+			}
+			if strings.Contains(rec.Body.String(), "/var/lib") {
+				t.Error("response leaks the internal error")
+			}
+		})
+	}
+}
+
+func TestWriteErrorLogsRouteTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(newLogger(&buf, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/wishes/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, errors.New("boom"))
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/wishes/SecretCat", nil))
+
+	if out := buf.String(); strings.Contains(out, "SecretCat") || !strings.Contains(out, `"route":"/api/v1/wishes/{userId}"`) {
+		t.Errorf("log = %s, want the route template without the user", out)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxQueryLimit — максимальный размер страницы
+const maxQueryLimit = 100
+
+// errInvalidCursor — курсор поврежден или получен для другой сортировки
+var errInvalidCursor = errors.New("invalid cursor")
+
+// wishSortFields — допустимые поля сортировки
+var wishSortFields = map[string]bool{"created": true, "price": true, "comfort": true, "priority": true}
+
+// WishQuery — параметры выборки желаний
+type WishQuery struct {
+	// Status — статус; пусто — активные
+	Status WishStatus
+	// Text — подстрока в названии (без учета регистра)
+	Text string
+	// Category — точное совпадение категории (без учета регистра)
+	Category string
+	// Tag — желание должно иметь эту метку
+	Tag string
+	// MinPrice, MaxPrice — диапазон цены в валюте профиля включительно; nil — без ограничения
+	MinPrice, MaxPrice *float64
+	// CreatedFrom, CreatedTo — диапазон даты создания; нулевое время — без ограничения
+	CreatedFrom, CreatedTo time.Time
+	// Sort — поле сортировки: created, price, comfort, priority; пусто — created
+	Sort string
+	// Desc — сортировка по убыванию
+	Desc bool
+	// Cursor — курсор из предыдущей страницы
+	Cursor string
+	// Limit — размер страницы; 0 — без пагинации
+	Limit int
+}
+
+// WishPage — страница выборки желаний
+type WishPage struct {
+	Items      []Wish
+	Total      int
+	NextCursor string
+}
+
+// wishCursor — позиция последнего элемента страницы
+type wishCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Key  int64  `json:"k"`
+	ID   string `json:"i"`
+}
+
+func encodeCursor(c wishCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (wishCursor, error) {
+	var c wishCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, errInvalidCursor
+	}
+	return c, nil
+}
+
+// wishSortKey возвращает целочисленный ключ сортировки.
+// Цена считается в копейках, даты — в наносекундах; недостижимая комфортная дата уходит в конец.
+func wishSortKey(w Wish, field string) int64 {
+	switch field {
+	case "price":
+		return int64(math.Round(w.BasePrice() * 100))
+	case "priority":
+		return int64(w.Priority.Rank())
+	case "comfort":
+		if d, ok := comfortDate(w); ok {
+			return d.UnixNano()
+		}
+		return math.MaxInt64
+	default:
+		return w.CreatedAt.UnixNano()
+	}
+}
+
+// matches проверяет фильтры запроса
+func (q WishQuery) matches(w Wish) bool {
+	if w.Status != q.Status {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(w.Title), q.Text) {
+		return false
+	}
+	if q.Category != "" && !strings.EqualFold(w.Category, q.Category) {
+		return false
+	}
+	if q.Tag != "" && !slices.Contains(w.Tags, q.Tag) {
+		return false
+	}
+	if q.MinPrice != nil && w.BasePrice() < *q.MinPrice {
+		return false
+	}
+	if q.MaxPrice != nil && w.BasePrice() > *q.MaxPrice {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && w.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && w.CreatedAt.After(q.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// runWishQuery фильтрует, сортирует и режет на страницы список желаний.
+// Порядок устойчив: при равных ключах сравниваются ID, поэтому курсор однозначен.
+func runWishQuery(list []Wish, q WishQuery) (WishPage, error) {
+	if q.Status == "" {
+		q.Status = StatusActive
+	}
+	if q.Sort == "" {
+		q.Sort = "created"
+	}
+	q.Text = strings.ToLower(strings.TrimSpace(q.Text))
+	q.Tag = strings.ToLower(strings.TrimSpace(q.Tag))
+
+	type keyed struct {
+		key int64
+		w   Wish
+	}
+	items := make([]keyed, 0, len(list))
+	for _, w := range list {
+		if q.matches(w) {
+			items = append(items, keyed{wishSortKey(w, q.Sort), w})
+		}
+	}
+
+	less := func(ak int64, aID string, bk int64, bID string) bool {
+		if ak != bk {
+			return (ak < bk) != q.Desc
+		}
+		if aID == bID {
+			return false
+		}
+		return (aID < bID) != q.Desc
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i].key, items[i].w.ID, items[j].key, items[j].w.ID)
+	})
+
+	page := WishPage{Total: len(items)}
+
+	start := 0
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil || c.Sort != q.Sort || c.Desc != q.Desc {
+			return WishPage{}, errInvalidCursor
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			return less(c.Key, c.ID, items[i].key, items[i].w.ID)
+		})
+	}
+
+	end := len(items)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		last := items[end-1]
+		page.NextCursor = encodeCursor(wishCursor{Sort: q.Sort, Desc: q.Desc, Key: last.key, ID: last.w.ID})
+	}
+
+	page.Items = make([]Wish, 0, end-start)
+	for _, it := range items[start:end] {
+		page.Items = append(page.Items, it.w)
+	}
+	return page, nil
+}
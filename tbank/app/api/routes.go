@@ -1,100 +1,94 @@
 package main
 
-import "github.com/gorilla/mux"
+import (
+	"github.com/gorilla/mux"
+	httpSwagger "github.com/swaggo/http-swagger"
+
+	_ "tbankwish/docs"
+)
+
+// apiPrefix — префикс версии API; совпадает с @BasePath в main.go
+const apiPrefix = "/api/v1"
 
 // NewRouter создает маршрутизатор с API-эндпоинтами.
+// publicURL — внешний адрес сервера для ссылок на ленту календаря; пусто — по адресу запроса.
 // @Summary Создает новый маршрутизатор с API-эндпоинтами
 // @Description Маршрутизатор с маршрутами для желаний и настроек.
 // @Tags router
 // @Accept  json
 // @Produce  json
-func NewRouter(storage *Storage) *mux.Router {
+func NewRouter(storage *Storage, sessions *SessionManager, notifier *Notifier, publicURL string) *mux.Router {
 	r := mux.NewRouter()
+	r.NotFoundHandler = accessLogMiddleware(metricsMiddleware(notFoundHandler()))
+	r.MethodNotAllowedHandler = accessLogMiddleware(metricsMiddleware(methodNotAllowedHandler()))
+	r.Use(accessLogMiddleware, metricsMiddleware)
+
+	// session — вход по нику
+	r.HandleFunc(apiPrefix+"/session", SignInHandler(sessions)).Methods("POST")
+	r.HandleFunc(apiPrefix+"/session", GetSessionHandler(sessions)).Methods("GET")
+	r.HandleFunc(apiPrefix+"/session", SignOutHandler()).Methods("DELETE")
 
-	api := r.PathPrefix("/api").Subrouter()
+	// users — реестр пользователей; идет раньше api, чтобы {ref} не проходил через identityMiddleware
+	users := r.PathPrefix(apiPrefix + "/users").Subrouter()
+	users.HandleFunc("", RegisterUserHandler(storage)).Methods("POST")
+	users.HandleFunc("/{ref}", GetUserHandler(storage, sessions)).Methods("GET")
+	users.HandleFunc("/{ref}", UpdateUserHandler(storage, sessions)).Methods("PATCH")
+	users.HandleFunc("/{ref}/pin", SetPINHandler(sessions)).Methods("PUT")
+	users.HandleFunc("/{ref}/pin", ClearPINHandler(sessions)).Methods("DELETE")
+
+	api := r.PathPrefix(apiPrefix).Subrouter()
+	// {userId} и {nick} во всех маршрутах ниже — ID или ник, обработчики получают ID;
+	// доступ к ним — только с сессией этого пользователя
+	api.Use(identityMiddleware(storage))
+	api.Use(sessionMiddleware(sessions))
 
 	// wishes
-	// @Summary Получить желания пользователя
-	// @Description Возвращает список желаний указанного пользователя
-	// @Tags wishes
-	// @Accept  json
-	// @Produce  json
-	// @Param userId path string true "ID пользователя"
-	// @Success 200 {array} Wish
-	// @Router /api/wishes/{userId} [get]
 	api.HandleFunc("/wishes/{userId}", GetWishesHandler(storage)).Methods("GET")
-	// @Summary Добавить желание
-	// @Description Добавляет новое желание для пользователя
-	// @Tags wishes
-	// @Accept  json
-	// @Produce  json
-	// @Param userId path string true "ID пользователя"
-	// @Param wish body Wish true "Объект желания"
-	// @Success 200 {object} Wish
-	// @Router /api/wishes/{userId} [post]
 	api.HandleFunc("/wishes/{userId}", AddWishHandler(storage)).Methods("POST")
-	// @Summary Переключить статус желания
-	// @Description Меняет статус желания (актуально/неактуально)
-	// @Tags wishes
-	// @Accept  json
-	// @Produce  json
-	// @Param userId path string true "ID пользователя"
-	// @Param wishId path string true "ID желания"
-	// @Success 200 {object} Wish
-	// @Router /api/wishes/{userId}/{wishId} [put]
 	api.HandleFunc("/wishes/{userId}/{wishId}", ToggleWishHandler(storage)).Methods("PUT")
-	// @Summary Удалить желание
-	// @Description Удаляет желание пользователя
-	// @Tags wishes
-	// @Accept  json
-	// @Produce  json
-	// @Param userId path string true "ID пользователя"
-	// @Param wishId path string true "ID желания"
-	// @Success 204
-	// @Router /api/wishes/{userId}/{wishId} [delete]
+	api.HandleFunc("/wishes/{userId}/{wishId}/status", SetWishStatusHandler(storage)).Methods("PUT")
+	api.HandleFunc("/wishes/{userId}/{wishId}", EditWishHandler(storage)).Methods("PATCH")
 	api.HandleFunc("/wishes/{userId}/{wishId}", RemoveWishHandler(storage)).Methods("DELETE")
+	api.HandleFunc("/wishes/{userId}/{wishId}/prices", GetPriceHistoryHandler(storage)).Methods("GET")
+	api.HandleFunc("/wishes/{userId}/{wishId}/merge", MergeWishHandler(storage)).Methods("POST")
 
 	// settings
-	// @Summary Получить настройки пользователя
-	// @Description Возвращает настройки пользователя по ID
-	// @Tags settings
-	// @Accept  json
-	// @Produce  json
-	// @Param userId path string true "ID пользователя"
-	// @Success 200 {object} Settings
-	// @Router /api/settings/{userId} [get]
 	api.HandleFunc("/settings/{userId}", GetSettingsHandler(storage)).Methods("GET")
-	// @Summary Сохранить настройки пользователя
-	// @Description Обновляет настройки пользователя
-	// @Tags settings
-	// @Accept  json
-	// @Produce  json
-	// @Param userId path string true "ID пользователя"
-	// @Param settings body Settings true "Объект настроек"
-	// @Success 200 {object} Settings
-	// @Router /api/settings/{userId} [post]
 	api.HandleFunc("/settings/{userId}", SaveSettingsHandler(storage)).Methods("POST")
+	api.HandleFunc("/settings/{userId}", PatchSettingsHandler(storage)).Methods("PATCH")
+	api.HandleFunc("/settings/{userId}/cooldowns/normalize", NormalizeCooldownsHandler(storage)).Methods("POST")
+	api.HandleFunc("/settings/{userId}/cooldowns/preset/{name}", ApplyCooldownPresetHandler(storage)).Methods("POST")
+	api.HandleFunc("/cooldown-presets", GetCooldownPresetsHandler()).Methods("GET")
+	api.HandleFunc("/settings/{userId}/history", GetSettingsHistoryHandler(storage)).Methods("GET")
+	api.HandleFunc("/settings/{userId}/history/{version:[0-9]+}/rollback", RollbackSettingsHandler(storage)).Methods("POST")
 
 	// profile
-	// @Summary Получить профиль пользователя
-	// @Description Возвращает профиль по нику пользователя
-	// @Tags profile
-	// @Accept  json
-	// @Produce  json
-	// @Param nick path string true "Ник пользователя"
-	// @Success 200 {object} UserProfile
-	// @Router /api/user/{nick} [get]
 	api.HandleFunc("/user/{nick}", GetProfileHandler(storage)).Methods("GET")
-	// @Summary Сохранить профиль пользователя
-	// @Description Сохраняет профиль по нику пользователя
-	// @Tags profile
-	// @Accept  json
-	// @Produce  json
-	// @Param nick path string true "Ник пользователя"
-	// @Param profile body UserProfile true "Объект профиля"
-	// @Success 200 {string} string "успешно сохранено"
-	// @Router /api/user/{nick} [post]
 	api.HandleFunc("/user/{nick}", SaveProfileHandler(storage)).Methods("POST")
+	api.HandleFunc("/user/{nick}", PatchProfileHandler(storage)).Methods("PATCH")
+	api.HandleFunc("/user/{nick}/history", GetProfileHistoryHandler(storage)).Methods("GET")
+	api.HandleFunc("/user/{nick}/history/{version:[0-9]+}/rollback", RollbackProfileHandler(storage)).Methods("POST")
+
+	// export
+	api.HandleFunc("/export/{userId}", ExportHandler(storage)).Methods("GET")
+
+	// import
+	api.HandleFunc("/import/{userId}", ImportHandler(storage)).Methods("POST")
+
+	// calendar
+	api.HandleFunc("/calendar/{userId}", GetCalendarTokenHandler(storage, publicURL)).Methods("GET")
+	api.HandleFunc("/calendar/{userId}", IssueCalendarTokenHandler(storage, publicURL)).Methods("POST")
+	api.HandleFunc("/ical/{token:[0-9a-f]+}.ics", CalendarFeedHandler(storage)).Methods("GET")
+
+	// planner
+	api.HandleFunc("/survey/{userId}", GetSurveyHandler(storage)).Methods("GET")
+	api.HandleFunc("/planner/{userId}", GetPlannerHandler(storage)).Methods("GET")
+
+	// notify
+	api.HandleFunc("/notify/{userId}", NotifyHandler(notifier)).Methods("POST")
+
+	// swagger — UI и спецификация OpenAPI, вне версии API
+	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
 	return r
 }
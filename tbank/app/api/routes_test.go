@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"tbankwish/docs"
+)
+
+// routeParamPattern — параметр маршрута с регулярным выражением: {version:[0-9]+}
+var routeParamPattern = regexp.MustCompile(`\{([^{}:]+):[^{}]*\}`)
+
+// registeredRoutes возвращает маршруты API в виде "METHOD /path" относительно apiPrefix
+func registeredRoutes(t *testing.T, r *mux.Router) map[string]bool {
+	t.Helper()
+	out := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil || !strings.HasPrefix(tpl, apiPrefix) {
+			return nil
+		}
+		path := routeParamPattern.ReplaceAllString(strings.TrimPrefix(tpl, apiPrefix), "{$1}")
+		for _, m := range methods {
+			out[m+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// specRoutes возвращает операции сгенерированной спецификации в виде "METHOD /path"
+func specRoutes(t *testing.T) (string, map[string]bool) {
+	t.Helper()
+	var spec struct {
+		BasePath string                    `json:"basePath"`
+		Paths    map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), &spec); err != nil {
+		t.Fatalf("parse generated spec: %v", err)
+	}
+	out := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for m := range ops {
+			out[strings.ToUpper(m)+" "+path] = true
+		}
+	}
+	return spec.BasePath, out
+}
+
+func missing(from, in map[string]bool) []string {
+	var out []string
+	for k := range from {
+		if !in[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TestOpenAPIMatchesRoutes падает, если спецификация в docs разошлась с маршрутами NewRouter.
+// После изменения маршрутов или аннотаций перегенерируйте docs: swag init -g main.go -o docs
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	storage := NewStorage()
+	router := NewRouter(storage, NewSessionManager(storage, RandomSecretKey()), NewNotifier(storage, DefaultConfig()), "")
+
+	basePath, documented := specRoutes(t)
+	if basePath != apiPrefix {
+		t.Errorf("spec basePath = %q, routes are served under %q", basePath, apiPrefix)
+	}
+
+	served := registeredRoutes(t, router)
+	for _, op := range missing(served, documented) {
+		t.Errorf("route %s is not documented in the spec", op)
+	}
+	for _, op := range missing(documented, served) {
+		t.Errorf("spec documents %s, but no such route is registered", op)
+	}
+}
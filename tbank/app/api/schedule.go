@@ -0,0 +1,91 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// surveySchedule — периодичность опросов «всё ещё хотите?»
+type surveySchedule struct {
+	// Freq — единица в терминах RRULE: MINUTELY, HOURLY, DAILY, WEEKLY, MONTHLY
+	Freq string
+	// Interval — количество единиц между опросами
+	Interval int
+}
+
+var surveyNumberRe = regexp.MustCompile(`\d+`)
+
+// surveyUnits — ключевые слова частоты (рус/англ) в порядке проверки
+var surveyUnits = []struct {
+	words []string
+	freq  string
+}{
+	{[]string{"минут", "minute"}, "MINUTELY"},
+	{[]string{"час", "hour"}, "HOURLY"},
+	{[]string{"ежедневно", "день", "дня", "дней", "сутки", "суток", "daily", "day"}, "DAILY"},
+	{[]string{"еженедельно", "недел", "weekly", "week"}, "WEEKLY"},
+	{[]string{"ежемесячно", "месяц", "monthly", "month"}, "MONTHLY"},
+}
+
+// parseSurveySchedule разбирает NotificationFreq из настроек («еженедельно», «каждые 30 минут», «раз в 2 недели»)
+func parseSurveySchedule(s string) (surveySchedule, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return surveySchedule{}, false
+	}
+
+	for _, u := range surveyUnits {
+		for _, word := range u.words {
+			if !strings.Contains(s, word) {
+				continue
+			}
+			interval := 1
+			if m := surveyNumberRe.FindString(s); m != "" {
+				if n, err := strconv.Atoi(m); err == nil && n > 0 {
+					interval = n
+				}
+			}
+			return surveySchedule{Freq: u.freq, Interval: interval}, true
+		}
+	}
+	return surveySchedule{}, false
+}
+
+// Next возвращает момент следующего опроса после from
+func (s surveySchedule) Next(from time.Time) time.Time {
+	switch s.Freq {
+	case "MINUTELY":
+		return from.Add(time.Duration(s.Interval) * time.Minute)
+	case "HOURLY":
+		return from.Add(time.Duration(s.Interval) * time.Hour)
+	case "DAILY":
+		return from.AddDate(0, 0, s.Interval)
+	case "WEEKLY":
+		return from.AddDate(0, 0, 7*s.Interval)
+	default:
+		return from.AddDate(0, s.Interval, 0)
+	}
+}
+
+// coolingEnd возвращает дату окончания охлаждения желания
+func coolingEnd(w Wish) time.Time {
+	days := w.CoolingDays
+	if days <= 0 {
+		days = w.RecommendedCooling
+	}
+	start := w.CreatedAt
+	if !w.CoolingStartedAt.IsZero() {
+		start = w.CoolingStartedAt
+	}
+	return start.AddDate(0, 0, days)
+}
+
+// comfortDate возвращает дату, когда покупка станет комфортной; false — если она недостижима
+func comfortDate(w Wish) (time.Time, bool) {
+	if w.ComfortMonths < 0 {
+		return time.Time{}, false
+	}
+	return w.CreatedAt.AddDate(0, w.ComfortMonths, 0), true
+}
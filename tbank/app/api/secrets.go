@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// sealedPrefix — префикс зашифрованного значения: enc:v1:<keyId>:<ключ данных>:<шифртекст>
+const sealedPrefix = "enc:v1:"
+
+var (
+	errSecretKeySize = errors.New("secret key must be 32 bytes")
+	errSealedFormat  = errors.New("malformed sealed secret")
+	errSecretKeyID   = errors.New("secret was sealed with another key")
+)
+
+// SecretBox шифрует секреты конвертом: каждое значение шифруется своим случайным
+// ключом данных (AES-256-GCM), а ключ данных — ключом сервера из конфигурации
+type SecretBox struct {
+	kek   cipher.AEAD
+	keyID string
+}
+
+// NewSecretBox создает шифровальщик с 32-байтным ключом сервера
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != 32 {
+		return nil, errSecretKeySize
+	}
+	kek, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &SecretBox{kek: kek, keyID: hex.EncodeToString(sum[:4])}, nil
+}
+
+// ParseSecretKey разбирает ключ сервера в base64
+func ParseSecretKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secret key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errSecretKeySize
+	}
+	return key, nil
+}
+
+// RandomSecretKey создает случайный ключ сервера
+func RandomSecretKey() []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal шифрует plain ключом aead; nonce записывается перед шифртекстом
+func seal(aead cipher.AEAD, plain []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, plain, nil)
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errSealedFormat
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// isSealed проверяет, зашифровано ли значение
+func isSealed(v string) bool {
+	return strings.HasPrefix(v, sealedPrefix)
+}
+
+// Seal шифрует секрет; пустая строка остается пустой
+func (b *SecretBox) Seal(plain string) string {
+	if plain == "" {
+		return ""
+	}
+	dek := RandomSecretKey()
+	aead, err := newGCM(dek)
+	if err != nil {
+		panic(err)
+	}
+	enc := base64.RawStdEncoding
+	return sealedPrefix + b.keyID + ":" +
+		enc.EncodeToString(seal(b.kek, dek)) + ":" +
+		enc.EncodeToString(seal(aead, []byte(plain)))
+}
+
+// Open расшифровывает секрет, зашифрованный Seal
+func (b *SecretBox) Open(sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	parts := strings.Split(strings.TrimPrefix(sealed, sealedPrefix), ":")
+	if !isSealed(sealed) || len(parts) != 3 {
+		return "", errSealedFormat
+	}
+	if parts[0] != b.keyID {
+		return "", errSecretKeyID
+	}
+
+	enc := base64.RawStdEncoding
+	wrapped, err1 := enc.DecodeString(parts[1])
+	ct, err2 := enc.DecodeString(parts[2])
+	if err1 != nil || err2 != nil {
+		return "", errSealedFormat
+	}
+	dek, err := open(b.kek, wrapped)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, ct)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// sealSecret готовит новое значение секрета к хранению. Секреты только для записи:
+// маска от клиента (он прислал обратно то, что получил в GET) сохраняет прежнее значение,
+// пустая строка удаляет секрет, уже зашифрованное этим ключом значение (откат версии)
+// сохраняется как есть, все остальное шифруется.
+func (b *SecretBox) sealSecret(prev, next string) string {
+	switch {
+	case next == secretMask:
+		return prev
+	case next == "":
+		return ""
+	case isSealed(next):
+		if _, err := b.Open(next); err == nil {
+			return next
+		}
+	}
+	return b.Seal(next)
+}
+
+// sealSettings шифрует секретные поля настроек перед сохранением
+func (b *SecretBox) sealSettings(prev, next Settings) Settings {
+	next.TelegramToken = b.sealSecret(prev.TelegramToken, next.TelegramToken)
+	next.SMTPPassword = b.sealSecret(prev.SMTPPassword, next.SMTPPassword)
+	return next
+}
+
+// openSettings возвращает настройки с расшифрованными секретами
+func (b *SecretBox) openSettings(set Settings) (Settings, error) {
+	var err error
+	if set.TelegramToken, err = b.Open(set.TelegramToken); err != nil {
+		return Settings{}, fmt.Errorf("telegramToken: %w", err)
+	}
+	if set.SMTPPassword, err = b.Open(set.SMTPPassword); err != nil {
+		return Settings{}, fmt.Errorf("smtpPassword: %w", err)
+	}
+	return set, nil
+}
+
+// Format выводит настройки в логи без секретов: и %v, и %+v печатают маску
+func (s Settings) Format(f fmt.State, verb rune) {
+	type plain Settings
+	masked := plain(maskSettings(s))
+	fmt.Fprintf(f, fmt.FormatString(f, verb), masked)
+}
+
+// Format выводит профиль в логи без сумм: видны только ник, валюта и категории
+func (p UserProfile) Format(f fmt.State, verb rune) {
+	fmt.Fprintf(f, "{Nick:%s Currency:%s Salary:%s Savings:%s BlockedCategories:%v}",
+		p.Nick, p.Currency, secretMask, secretMask, p.BlockedCategories)
+}
+
+// logSecretPatterns — то, что похоже на секрет и не должно попасть в лог,
+// даже если его напечатали строкой: конверты, токены Telegram-ботов, пароли в URL
+var logSecretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`enc:v1:[0-9a-f]+:[A-Za-z0-9+/]+:[A-Za-z0-9+/]+`), secretMask},
+	{regexp.MustCompile(`\b\d{6,12}:[A-Za-z0-9_-]{30,}\b`), secretMask},
+	{regexp.MustCompile(`(?i)((?:password|passwd|token|secret)["']?\s*[:=]\s*["']?)[^\s"',}]+`), "${1}" + secretMask},
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`), "${1}" + secretMask + "@"},
+}
+
+// redactingWriter — вывод логов, который вырезает секреты из каждой записи
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter оборачивает вывод логов слоем редактирования секретов
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	out := p
+	for _, pat := range logSecretPatterns {
+		out = pat.re.ReplaceAll(out, []byte(pat.repl))
+	}
+	if _, err := r.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
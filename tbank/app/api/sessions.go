@@ -0,0 +1,376 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// sessionCookie — имя cookie с токеном сессии
+	sessionCookie = "twish_session"
+	// sessionTTL — срок жизни токена
+	sessionTTL = 30 * 24 * time.Hour
+	// pinIterations — число итераций PBKDF2 для PIN
+	pinIterations = 100_000
+	// maxPINFailures — сколько неверных PIN с одного клиента допускается до его блокировки
+	maxPINFailures = 5
+	// maxNickPINFailures — сколько неверных PIN со всех клиентов допускается до блокировки ника
+	maxNickPINFailures = 20
+	// pinLockout — первая блокировка; каждая следующая ошибка ее удваивает
+	pinLockout = 5 * time.Minute
+	// maxPINLockout — предел блокировки
+	maxPINLockout = 24 * time.Hour
+)
+
+var (
+	errNoSession      = errors.New("sign in required")
+	errInvalidSession = errors.New("invalid or expired session")
+	errForeignUser    = errors.New("session belongs to another user")
+	errPINRequired    = errors.New("pin required")
+	errWrongPIN       = errors.New("wrong pin")
+	errPINLocked      = errors.New("too many wrong pins, try again later")
+	errInvalidPIN     = errors.New("pin must be 4-8 digits")
+)
+
+// pinPattern — допустимый PIN
+var pinPattern = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+// sessionClaims — содержимое токена сессии
+type sessionClaims struct {
+	// UserID — внутренний ID пользователя
+	UserID string `json:"uid"`
+	// Epoch — поколение сессий пользователя; смена PIN делает старые токены недействительными
+	Epoch int `json:"ep"`
+	// Expires — срок действия, unix-секунды
+	Expires int64 `json:"exp"`
+}
+
+// Session — выданная сессия
+// @Description Токен сессии и пользователь, за которым он закреплен.
+type Session struct {
+	// Token — токен для заголовка Authorization: Bearer или cookie twish_session
+	Token string `json:"token"`
+	// ExpiresAt — срок действия токена
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      User      `json:"user"`
+}
+
+// SignInRequest — вход по нику
+// @Description Ник и PIN, если пользователь его включил.
+type SignInRequest struct {
+	// Nick — ник; новый ник регистрируется
+	// example: "TestMeowUser"
+	Nick string `json:"nick" validate:"required,maxlen=64"`
+	// PIN — PIN, если включен
+	// example: "1234"
+	PIN string `json:"pin" validate:"maxlen=8"`
+}
+
+// PINRequest — включение, смена или отключение PIN
+// @Description Новый PIN и текущий, если PIN уже включен.
+type PINRequest struct {
+	// PIN — новый PIN из 4-8 цифр
+	// example: "1234"
+	PIN string `json:"pin" validate:"maxlen=8"`
+	// CurrentPIN — текущий PIN
+	// example: "0000"
+	CurrentPIN string `json:"currentPin" validate:"maxlen=8"`
+}
+
+// SessionManager выдает и проверяет подписанные токены сессий.
+// Паролей нет: вход по нику, при желании пользователь защищает ник PIN-кодом.
+type SessionManager struct {
+	storage *Storage
+	key     []byte
+	// Required — проверять, что пользователь в пути совпадает с сессией
+	Required bool
+}
+
+// NewSessionManager создает менеджер сессий с ключом подписи
+func NewSessionManager(storage *Storage, key []byte) *SessionManager {
+	return &SessionManager{storage: storage, key: key, Required: true}
+}
+
+func (m *SessionManager) sign(payload string) string {
+	mac := hmac.New(sha256.New, m.key)
+	io.WriteString(mac, payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Issue выдает токен пользователю
+func (m *SessionManager) Issue(u User, epoch int) Session {
+	exp := time.Now().Add(sessionTTL).Truncate(time.Second)
+	b, _ := json.Marshal(sessionClaims{UserID: u.ID, Epoch: epoch, Expires: exp.Unix()})
+	payload := "v1." + base64.RawURLEncoding.EncodeToString(b)
+	return Session{Token: payload + "." + m.sign(payload), ExpiresAt: exp, User: u}
+}
+
+// Verify проверяет подпись, срок и поколение токена и возвращает ID пользователя
+func (m *SessionManager) Verify(token string) (string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !strings.HasPrefix(token, "v1.") {
+		return "", errInvalidSession
+	}
+	payload, sig := token[:i], token[i+1:]
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(m.sign(payload))) != 1 {
+		return "", errInvalidSession
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(payload, "v1."))
+	if err != nil {
+		return "", errInvalidSession
+	}
+	var c sessionClaims
+	if err := json.Unmarshal(b, &c); err != nil {
+		return "", errInvalidSession
+	}
+	if time.Now().Unix() > c.Expires {
+		return "", errInvalidSession
+	}
+	if epoch, ok := m.storage.SessionEpoch(c.UserID); !ok || epoch != c.Epoch {
+		return "", errInvalidSession
+	}
+	return c.UserID, nil
+}
+
+// requestToken достает токен из Authorization: Bearer или cookie
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// SessionUser возвращает ID пользователя из сессии запроса
+func (m *SessionManager) SessionUser(r *http.Request) (string, error) {
+	token := requestToken(r)
+	if token == "" {
+		return "", errNoSession
+	}
+	return m.Verify(token)
+}
+
+// Authorize проверяет, что запрос сделан от имени userId
+func (m *SessionManager) Authorize(r *http.Request, userId string) error {
+	if !m.Required {
+		return nil
+	}
+	uid, err := m.SessionUser(r)
+	if err != nil {
+		return err
+	}
+	if uid != userId {
+		return errForeignUser
+	}
+	return nil
+}
+
+// sessionMiddleware пропускает запрос к данным пользователя только с его сессией.
+// Ставится после identityMiddleware, когда {userId} и {nick} уже переведены во внутренний ID.
+func sessionMiddleware(sessions *SessionManager) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			vars := mux.Vars(r)
+			for _, key := range []string{"userId", "nick"} {
+				id, ok := vars[key]
+				if !ok {
+					continue
+				}
+				if err := sessions.Authorize(r, id); err != nil {
+					writeError(w, r, err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hashPIN считает PBKDF2-SHA256 от PIN с солью
+func hashPIN(pin string, salt []byte) []byte {
+	key, err := pbkdf2.Key(sha256.New, pin, salt, pinIterations, 32)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// newPINSalt создает случайную соль для PIN
+func newPINSalt() []byte {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		panic(err)
+	}
+	return salt
+}
+
+// clientAddr возвращает адрес клиента для лимита неверных PIN.
+// Заголовкам прокси не доверяем: их может подставить сам клиент.
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// writeSession отдает сессию телом и cookie
+func writeSession(w http.ResponseWriter, s Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.Token,
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// SignInHandler создает обработчик входа по нику
+// @Summary Войти по нику
+// @Description Выдает подписанный токен сессии для ника. Новый ник регистрируется. Если у ника включен PIN, он обязателен.
+// @Tags session
+// @Param credentials body SignInRequest true "Ник и PIN"
+// @Accept json
+// @Produce json
+// @Success 200 {object} Session
+// @Failure 401 {object} Problem "pin required"
+// @Failure 429 {object} Problem "too many wrong pins"
+// @Router /session [post]
+func SignInHandler(sessions *SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body SignInRequest
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+
+		u, epoch, err := sessions.storage.SignIn(r.Context(), body.Nick, body.PIN, clientAddr(r))
+		if err != nil {
+			logger("handler").WarnContext(r.Context(), "sign in failed", "error", err)
+			writeError(w, r, err)
+			return
+		}
+
+		setRequestUser(r.Context(), u.ID)
+		logger("handler").InfoContext(r.Context(), "signed in", "user_id", u.ID)
+		writeSession(w, sessions.Issue(u, epoch))
+	}
+}
+
+// GetSessionHandler создает обработчик текущей сессии
+// @Summary Текущая сессия
+// @Description Возвращает пользователя, за которым закреплен токен
+// @Tags session
+// @Produce json
+// @Success 200 {object} User
+// @Failure 401 {object} Problem "sign in required"
+// @Security SessionToken
+// @Router /session [get]
+func GetSessionHandler(sessions *SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, err := sessions.SessionUser(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		u, _ := sessions.storage.ResolveUser(uid)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	}
+}
+
+// SignOutHandler создает обработчик выхода
+// @Summary Выйти
+// @Description Удаляет cookie сессии. Чтобы отозвать все токены, смените PIN.
+// @Tags session
+// @Success 204
+// @Router /session [delete]
+func SignOutHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetPINHandler создает обработчик включения и смены PIN
+// @Summary Включить или сменить PIN
+// @Description Защищает ник PIN-кодом. Если PIN уже включен, нужен текущий. Старые токены перестают действовать, в ответе — новая сессия.
+// @Tags session
+// @Param ref path string true "ID или ник"
+// @Param pin body PINRequest true "Новый и текущий PIN"
+// @Accept json
+// @Produce json
+// @Success 200 {object} Session
+// @Failure 401 {object} Problem "wrong pin"
+// @Failure 403 {object} Problem "session belongs to another user"
+// @Security SessionToken
+// @Router /users/{ref}/pin [put]
+func SetPINHandler(sessions *SessionManager) http.HandlerFunc {
+	return changePINHandler(sessions, true)
+}
+
+// ClearPINHandler создает обработчик отключения PIN
+// @Summary Отключить PIN
+// @Description Снимает защиту PIN-кодом; нужен текущий PIN. В ответе — новая сессия.
+// @Tags session
+// @Param ref path string true "ID или ник"
+// @Param pin body PINRequest true "Текущий PIN"
+// @Accept json
+// @Produce json
+// @Success 200 {object} Session
+// @Failure 401 {object} Problem "wrong pin"
+// @Failure 403 {object} Problem "session belongs to another user"
+// @Security SessionToken
+// @Router /users/{ref}/pin [delete]
+func ClearPINHandler(sessions *SessionManager) http.HandlerFunc {
+	return changePINHandler(sessions, false)
+}
+
+func changePINHandler(sessions *SessionManager, enable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := sessions.storage.ResolveUser(mux.Vars(r)["ref"])
+		if !ok {
+			writeError(w, r, errUserNotFound)
+			return
+		}
+		if err := sessions.Authorize(r, u.ID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		var body PINRequest
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if !enable {
+			body.PIN = ""
+		}
+
+		u, epoch, err := sessions.storage.SetUserPIN(r.Context(), u.ID, body.CurrentPIN, body.PIN, clientAddr(r))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "PIN changed", "user_id", u.ID, "pin_enabled", u.PINEnabled)
+		writeSession(w, sessions.Issue(u, epoch))
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionTokenSignVerify(t *testing.T) {
+	storage := NewStorage()
+	u, err := storage.RegisterUser(context.Background(), "cat", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewSessionManager(storage, RandomSecretKey())
+
+	s := m.Issue(u, 0)
+	if uid, err := m.Verify(s.Token); err != nil || uid != u.ID {
+		t.Fatalf("Verify() = %q, %v; want %q", uid, err, u.ID)
+	}
+
+	payload, sig, _ := strings.Cut(strings.TrimPrefix(s.Token, "v1."), ".")
+	forged, _ := json.Marshal(sessionClaims{UserID: "someone-else", Expires: time.Now().Add(time.Hour).Unix()})
+	expired, _ := json.Marshal(sessionClaims{UserID: u.ID, Expires: time.Now().Add(-time.Minute).Unix()})
+	expiredPayload := "v1." + base64.RawURLEncoding.EncodeToString(expired)
+	bad := map[string]string{
+		"empty":         "",
+		"no signature":  "v1." + payload,
+		"wrong version": "v2." + payload + "." + sig,
+		"forged claims": "v1." + base64.RawURLEncoding.EncodeToString(forged) + "." + sig,
+		"another key":   NewSessionManager(storage, RandomSecretKey()).Issue(u, 0).Token,
+		"expired":       expiredPayload + "." + m.sign(expiredPayload),
+		"unknown user":  m.Issue(User{ID: "usr_missing"}, 0).Token,
+		"truncated sig": s.Token[:len(s.Token)-3],
+	}
+	for name, token := range bad {
+		if _, err := m.Verify(token); !errors.Is(err, errInvalidSession) {
+			t.Errorf("%s: Verify() error = %v, want %v", name, err, errInvalidSession)
+		}
+	}
+}
+
+func TestPINChangeRevokesSessions(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	m := NewSessionManager(storage, RandomSecretKey())
+
+	u, epoch, err := storage.SignIn(ctx, "cat", "", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := m.Issue(u, epoch)
+
+	u, epoch, err = storage.SetUserPIN(ctx, u.ID, "", "1234", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(old.Token); !errors.Is(err, errInvalidSession) {
+		t.Errorf("token issued before the PIN change still works: %v", err)
+	}
+	if _, err := m.Verify(m.Issue(u, epoch).Token); err != nil {
+		t.Errorf("new token: %v", err)
+	}
+
+	if _, _, err := storage.SignIn(ctx, "cat", "", "10.0.0.1"); !errors.Is(err, errPINRequired) {
+		t.Errorf("sign in without PIN: %v, want %v", err, errPINRequired)
+	}
+	if _, _, err := storage.SetUserPIN(ctx, u.ID, "0000", "", "10.0.0.1"); !errors.Is(err, errWrongPIN) {
+		t.Errorf("disable PIN with a wrong current PIN: %v, want %v", err, errWrongPIN)
+	}
+	if _, _, err := storage.SignIn(ctx, "cat", "1234", "10.0.0.1"); err != nil {
+		t.Errorf("sign in with the new PIN: %v", err)
+	}
+}
+
+func TestPINLockoutPerClient(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	u, _, _ := storage.SignIn(ctx, "cat", "", "owner")
+	if _, _, err := storage.SetUserPIN(ctx, u.ID, "", "1234", "owner"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxPINFailures; i++ {
+		if _, _, err := storage.SignIn(ctx, "cat", "0000", "attacker"); !errors.Is(err, errWrongPIN) {
+			t.Fatalf("attempt %d: %v, want %v", i+1, err, errWrongPIN)
+		}
+	}
+	if _, _, err := storage.SignIn(ctx, "cat", "1234", "attacker"); !errors.Is(err, errPINLocked) {
+		t.Errorf("locked client signed in: %v, want %v", err, errPINLocked)
+	}
+	// чужие ошибки не мешают владельцу войти со своего устройства
+	if _, _, err := storage.SignIn(ctx, "cat", "1234", "owner"); err != nil {
+		t.Errorf("owner is locked out by another client: %v", err)
+	}
+	// и вход владельца не снимает блокировку с атакующего
+	if _, _, err := storage.SignIn(ctx, "cat", "1234", "attacker"); !errors.Is(err, errPINLocked) {
+		t.Errorf("client lock was lifted by another client's sign in: %v", err)
+	}
+}
+
+func TestPINLockoutPerNick(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	u, _, _ := storage.SignIn(ctx, "cat", "", "owner")
+	if _, _, err := storage.SetUserPIN(ctx, u.ID, "", "1234", "owner"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxNickPINFailures; i++ {
+		storage.SignIn(ctx, "cat", "0000", fmt.Sprintf("10.0.0.%d", i))
+	}
+	if _, _, err := storage.SignIn(ctx, "cat", "0000", "10.0.1.1"); !errors.Is(err, errPINLocked) {
+		t.Errorf("distributed guessing is not limited: %v, want %v", err, errPINLocked)
+	}
+}
+
+func TestPINBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{maxPINFailures - 1, 0},
+		{maxPINFailures, pinLockout},
+		{maxPINFailures + 1, 2 * pinLockout},
+		{maxPINFailures + 3, 8 * pinLockout},
+		{maxPINFailures + 100, maxPINLockout},
+	}
+	for _, tt := range tests {
+		if got := pinBackoff(tt.failures, maxPINFailures); got != tt.want {
+			t.Errorf("pinBackoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
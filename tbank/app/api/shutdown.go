@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Server запускает HTTP-сервер и фоновые задачи и останавливает их по SIGINT/SIGTERM.
+// Порядок остановки: сервер перестает принимать соединения и дожидается текущих запросов,
+// фоновые задачи получают отмену контекста, затем выполняются хуки OnStop в обратном порядке.
+// Все шаги укладываются в общий срок timeout.
+type Server struct {
+	http    *http.Server
+	timeout time.Duration
+	workers []serverWorker
+	hooks   []serverHook
+}
+
+type serverWorker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+type serverHook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// NewServer создает сервер поверх srv со сроком остановки timeout
+func NewServer(srv *http.Server, timeout time.Duration) *Server {
+	return &Server{http: srv, timeout: timeout}
+}
+
+// Go регистрирует фоновую задачу; run должна вернуться после отмены ctx
+func (s *Server) Go(name string, run func(ctx context.Context)) {
+	s.workers = append(s.workers, serverWorker{name: name, run: run})
+}
+
+// OnStop регистрирует хук остановки: он вызывается после остановки HTTP-сервера и фоновых задач
+func (s *Server) OnStop(name string, stop func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, serverHook{name: name, stop: stop})
+}
+
+// Run запускает сервер и задачи и ждет сигнала, отмены ctx или падения HTTP-сервера.
+// Возвращает ошибку HTTP-сервера или ошибки остановки.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	var wg sync.WaitGroup
+	for _, worker := range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.run(workersCtx)
+			logger("server").Info("worker stopped", "worker", worker.name)
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger("server").Info("listening", "addr", s.http.Addr)
+		serveErr <- s.http.ListenAndServe()
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger("server").Info("shutting down", "deadline", s.timeout.String())
+	case runErr = <-serveErr:
+		logger("server").Error("HTTP server stopped", "error", runErr)
+	}
+	// повторный сигнал во время остановки завершает процесс сразу
+	stopSignals()
+
+	deadline, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var errs []error
+	if runErr == nil {
+		if err := s.http.Shutdown(deadline); err != nil {
+			errs = append(errs, err)
+			logger("server").Error("drain requests", "error", err)
+		}
+	}
+
+	cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-deadline.Done():
+		errs = append(errs, errors.New("background workers did not stop before the deadline"))
+		logger("server").Error("workers did not stop before the deadline")
+	}
+
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		hook := s.hooks[i]
+		if err := hook.stop(deadline); err != nil {
+			errs = append(errs, err)
+			logger("server").Error("stop hook failed", "hook", hook.name, "error", err)
+		}
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	logger("server").Info("stopped")
+	return nil
+}
@@ -1,32 +1,410 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 )
 
-// Storage хранит данные и настройки пользователей
+// Storage хранит данные и настройки пользователей.
+// Все данные пользователя хранятся по его постоянному внутреннему ID.
 type Storage struct {
-	wishes    map[string][]Wish
-	settings  map[string]Settings
-	profiles  map[string]UserProfile
-	completed map[string][]Wish
-	canceled  map[string][]Wish
-	mu        sync.Mutex
+	// users — реестр пользователей по ID; userByNick, userByPhone — индексы
+	users       map[string]User
+	userByNick  map[string]string
+	userByPhone map[string]string
+	// wishes — все желания пользователя независимо от статуса, новые в начале
+	wishes   map[string][]Wish
+	settings map[string]Settings
+	profiles map[string]UserProfile
+	// calendarTokens — токены iCalendar-ленты: userId -> token
+	calendarTokens map[string]string
+	// priceHistory — наблюдения цены: userId/wishId -> точки
+	priceHistory map[string][]PricePoint
+	// settingsHistory, profileHistory — версии настроек и профиля, новые в конце
+	settingsHistory map[string][]SettingsVersion
+	profileHistory  map[string][]ProfileVersion
+	// pinClients — неверные PIN по паре пользователь и клиент: userId + "|" + адрес
+	pinClients map[string]pinClientState
+	// rates — курсы для пересчета цен в валюту профиля
+	rates RateProvider
+	// secrets — шифрование токенов и паролей в настройках
+	secrets *SecretBox
+	mu      sync.Mutex
 }
 
 // NewStorage создает новый хранилище
 func NewStorage() *Storage {
 	return &Storage{
-		wishes:    make(map[string][]Wish),
-		settings:  make(map[string]Settings),
-		profiles:  make(map[string]UserProfile),
-		completed: make(map[string][]Wish),
-		canceled:  make(map[string][]Wish),
+		users:       make(map[string]User),
+		userByNick:  make(map[string]string),
+		userByPhone: make(map[string]string),
+
+		wishes:   make(map[string][]Wish),
+		settings: make(map[string]Settings),
+		profiles: make(map[string]UserProfile),
+
+		calendarTokens: make(map[string]string),
+		priceHistory:   make(map[string][]PricePoint),
+
+		settingsHistory: make(map[string][]SettingsVersion),
+		profileHistory:  make(map[string][]ProfileVersion),
+		pinClients:      make(map[string]pinClientState),
+		rates:           DefaultRates(),
+		secrets:         mustSecretBox(RandomSecretKey()),
 	}
 }
 
+func mustSecretBox(key []byte) *SecretBox {
+	b, err := NewSecretBox(key)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Flush дожидается текущих изменений и сохраняет их перед остановкой сервера.
+// Бэкенд memory держит данные только в памяти, поэтому сохранять ему нечего;
+// хранилища на диске будут дописывать данные здесь.
+func (s *Storage) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ctx.Err()
+}
+
+// storageLog — логгер хранилища; ID запроса берется из ctx методов
+func storageLog() *slog.Logger { return logger("storage") }
+
+// errStorageUnavailable — хранилище не ответило вовремя
+var errStorageUnavailable = errors.New("storage is not responding")
+
+// pingRetry — пауза между попытками Ping получить блокировку
+const pingRetry = 10 * time.Millisecond
+
+// Ping проверяет, что хранилище отвечает: блокировку удается получить до истечения ctx.
+// Используется TryLock, чтобы зависшее хранилище не копило ждущие горутины проверок.
+func (s *Storage) Ping(ctx context.Context) error {
+	ticker := time.NewTicker(pingRetry)
+	defer ticker.Stop()
+	for {
+		if s.mu.TryLock() {
+			s.mu.Unlock()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errStorageUnavailable
+		case <-ticker.C:
+		}
+	}
+}
+
+// SetSecretBox заменяет шифровальщик секретов; уже сохраненные секреты
+// должны быть зашифрованы тем же ключом
+func (s *Storage) SetSecretBox(b *SecretBox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets = b
+}
+
+// NotificationSettings возвращает настройки с расшифрованными токенами и паролями
+// для отправки уведомлений. Наружу через API они не отдаются.
+func (s *Storage) NotificationSettings(userId string) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.secrets.openSettings(s.settings[userId])
+}
+
+// SetRateProvider заменяет источник курсов валют
+func (s *Storage) SetRateProvider(rates RateProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rates = rates
+}
+
+// Rates возвращает текущий источник курсов валют
+func (s *Storage) Rates() RateProvider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rates
+}
+
+// ResolveUser находит пользователя по внутреннему ID или нику без учета регистра
+func (s *Storage) ResolveUser(ref string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resolveUserLocked(ref)
+}
+
+func (s *Storage) resolveUserLocked(ref string) (User, bool) {
+	if u, ok := s.users[ref]; ok {
+		return u, true
+	}
+	if id, ok := s.userByNick[nickKey(ref)]; ok {
+		return s.users[id], true
+	}
+	return User{}, false
+}
+
+// RegisterUser создает пользователя с уникальным ником и необязательным телефоном
+func (s *Storage) RegisterUser(ctx context.Context, nick, phone string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.registerUserLocked(ctx, nick, phone)
+}
+
+func (s *Storage) registerUserLocked(ctx context.Context, nick, phone string) (User, error) {
+	nick = strings.TrimSpace(nick)
+	if err := validateNick(nick); err != nil {
+		return User{}, err
+	}
+	if _, taken := s.userByNick[nickKey(nick)]; taken {
+		return User{}, errNickTaken
+	}
+	phone, err := normalizePhone(phone)
+	if err != nil {
+		return User{}, err
+	}
+	if _, taken := s.userByPhone[phone]; phone != "" && taken {
+		return User{}, errPhoneTaken
+	}
+
+	u := User{ID: userIDPrefix + generateUID(), Nick: nick, Phone: phone, CreatedAt: time.Now()}
+	s.users[u.ID] = u
+	s.userByNick[nickKey(nick)] = u.ID
+	if phone != "" {
+		s.userByPhone[phone] = u.ID
+	}
+	storageLog().InfoContext(ctx, "user registered", "user_id", u.ID)
+	return u, nil
+}
+
+// UpdateUser меняет ник и/или телефон пользователя. Данные привязаны к ID,
+// поэтому при смене ника ничего не теряется; старый ник освобождается.
+func (s *Storage) UpdateUser(ctx context.Context, ref string, in UserInput) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.resolveUserLocked(ref)
+	if !ok {
+		return User{}, errUserNotFound
+	}
+	next := u
+
+	if nick := strings.TrimSpace(in.Nick); nick != "" && nick != u.Nick {
+		if err := validateNick(nick); err != nil {
+			return User{}, err
+		}
+		if id, taken := s.userByNick[nickKey(nick)]; taken && id != u.ID {
+			return User{}, errNickTaken
+		}
+		next.Nick = nick
+	}
+	switch in.Phone {
+	case "":
+	case "-":
+		next.Phone = ""
+	default:
+		phone, err := normalizePhone(in.Phone)
+		if err != nil {
+			return User{}, err
+		}
+		if id, taken := s.userByPhone[phone]; taken && id != u.ID {
+			return User{}, errPhoneTaken
+		}
+		next.Phone = phone
+	}
+
+	delete(s.userByNick, nickKey(u.Nick))
+	s.userByNick[nickKey(next.Nick)] = u.ID
+	if u.Phone != "" {
+		delete(s.userByPhone, u.Phone)
+	}
+	if next.Phone != "" {
+		s.userByPhone[next.Phone] = u.ID
+	}
+	s.users[u.ID] = next
+
+	if p, ok := s.profiles[u.ID]; ok {
+		p.Nick = next.Nick
+		s.profiles[u.ID] = p
+	}
+	if next.Nick != u.Nick {
+		storageLog().InfoContext(ctx, "user renamed", "user_id", u.ID)
+	}
+	return next, nil
+}
+
+// Nick возвращает ник пользователя по ID; для незарегистрированного — сам ID
+func (s *Storage) Nick(userId string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nickLocked(userId)
+}
+
+func (s *Storage) nickLocked(userId string) string {
+	if u, ok := s.users[userId]; ok {
+		return u.Nick
+	}
+	return userId
+}
+
+// SessionEpoch возвращает поколение сессий пользователя
+func (s *Storage) SessionEpoch(userId string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userId]
+	return u.epoch, ok
+}
+
+// pinBackoff возвращает блокировку после failures неверных PIN при пороге threshold:
+// pinLockout, дальше удваивается с каждой ошибкой до maxPINLockout
+func pinBackoff(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	return min(pinLockout<<min(failures-threshold, 16), maxPINLockout)
+}
+
+// beginPINCheckLocked проверяет блокировки и начинает проверку PIN; nil — PIN не включен.
+// Попытка сразу засчитывается как ошибка и отменяется при верном PIN, чтобы параллельные
+// запросы не обходили лимиты, пока считается хеш. Лимиты два: с одного клиента
+// (maxPINFailures) — он не мешает владельцу войти с другого устройства, и по нику
+// со всех клиентов (maxNickPINFailures) — против перебора с множества адресов.
+// Счетчики не сбрасываются со временем, только верным PIN.
+func (s *Storage) beginPINCheckLocked(ctx context.Context, u User, client, pin string) (*pinAttempt, error) {
+	if !u.PINEnabled {
+		return nil, nil
+	}
+	now := time.Now()
+	key := u.ID + "|" + client
+	c := s.pinClients[key]
+	if now.Before(u.pinLockedUntil) || now.Before(c.lockedUntil) {
+		return nil, errPINLocked
+	}
+	if pin == "" {
+		return nil, errPINRequired
+	}
+
+	c.failures++
+	if d := pinBackoff(c.failures, maxPINFailures); d > 0 {
+		c.lockedUntil = now.Add(d)
+		storageLog().WarnContext(ctx, "too many wrong PINs from client, sign in locked", "user_id", u.ID, "client", client, "lockout", d)
+	}
+	s.pinClients[key] = c
+	u.pinFailures++
+	if d := pinBackoff(u.pinFailures, maxNickPINFailures); d > 0 {
+		u.pinLockedUntil = now.Add(d)
+		storageLog().WarnContext(ctx, "too many wrong PINs, sign in locked", "user_id", u.ID, "lockout", d)
+	}
+	s.users[u.ID] = u
+	return &pinAttempt{userID: u.ID, client: client, pin: pin, salt: u.pinSalt}, nil
+}
+
+// finishPINCheckLocked сверяет хеш, посчитанный вне блокировки, с сохраненным.
+// Верный PIN снимает счетчики и блокировки; если PIN успели сменить, попытка неверна.
+func (s *Storage) finishPINCheckLocked(a *pinAttempt, hash []byte) error {
+	if a == nil {
+		return nil
+	}
+	u, ok := s.users[a.userID]
+	if !ok {
+		return errUserNotFound
+	}
+	if !u.PINEnabled || subtle.ConstantTimeCompare(u.pinSalt, a.salt) != 1 ||
+		subtle.ConstantTimeCompare(hash, u.pinHash) != 1 {
+		return errWrongPIN
+	}
+	delete(s.pinClients, a.userID+"|"+a.client)
+	u.pinFailures, u.pinLockedUntil = 0, time.Time{}
+	s.users[u.ID] = u
+	return nil
+}
+
+// hashAttempt считает хеш проверяемого PIN; вызывается без блокировки хранилища
+func hashAttempt(a *pinAttempt) []byte {
+	if a == nil {
+		return nil
+	}
+	return hashPIN(a.pin, a.salt)
+}
+
+// SignIn находит или регистрирует пользователя по нику и проверяет PIN.
+// client — адрес клиента для лимита неверных PIN.
+// Возвращает пользователя и текущее поколение сессий.
+func (s *Storage) SignIn(ctx context.Context, nick, pin, client string) (User, int, error) {
+	s.mu.Lock()
+	u, ok := s.resolveUserLocked(nick)
+	if !ok {
+		var err error
+		if u, err = s.registerUserLocked(ctx, nick, ""); err != nil {
+			s.mu.Unlock()
+			return User{}, 0, err
+		}
+	}
+	attempt, err := s.beginPINCheckLocked(ctx, u, client, pin)
+	s.mu.Unlock()
+	if err != nil {
+		return User{}, 0, err
+	}
+
+	hash := hashAttempt(attempt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.finishPINCheckLocked(attempt, hash); err != nil {
+		return User{}, 0, err
+	}
+	u = s.users[u.ID]
+	return u, u.epoch, nil
+}
+
+// SetUserPIN включает, меняет или (при пустом pin) отключает PIN пользователя.
+// Если PIN уже включен, нужен текущий. Поколение сессий увеличивается,
+// так что выданные раньше токены перестают действовать.
+func (s *Storage) SetUserPIN(ctx context.Context, userId, currentPIN, pin, client string) (User, int, error) {
+	if pin != "" && !pinPattern.MatchString(pin) {
+		return User{}, 0, errInvalidPIN
+	}
+
+	s.mu.Lock()
+	u, ok := s.users[userId]
+	if !ok {
+		s.mu.Unlock()
+		return User{}, 0, errUserNotFound
+	}
+	attempt, err := s.beginPINCheckLocked(ctx, u, client, currentPIN)
+	s.mu.Unlock()
+	if err != nil {
+		return User{}, 0, err
+	}
+
+	hash := hashAttempt(attempt)
+	var salt, newHash []byte
+	if pin != "" {
+		salt = newPINSalt()
+		newHash = hashPIN(pin, salt)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.finishPINCheckLocked(attempt, hash); err != nil {
+		return User{}, 0, err
+	}
+	u = s.users[userId]
+	u.PINEnabled, u.pinHash, u.pinSalt = pin != "", newHash, salt
+	u.epoch++
+	s.users[u.ID] = u
+	storageLog().InfoContext(ctx, "PIN changed, sessions revoked", "user_id", u.ID, "pin_enabled", u.PINEnabled)
+	return u, u.epoch, nil
+}
+
 // copyWishes создает копию среза желаний
 func copyWishes(src []Wish) []Wish {
 	out := make([]Wish, len(src))
@@ -35,71 +413,78 @@ func copyWishes(src []Wish) []Wish {
 }
 
 // GetWishes возвращает список желаний пользователя
-// @Summary Получить список желаний пользователя
-// @Description Возвращает все желания пользователя по его ID
-// @Tags wishes
-// @Param userId path string true "ID пользователя"
-// @Success 200 {array} Wish
-// @Router /users/{userId}/wishes [get]
-func (s *Storage) GetWishes(userId string, status string) []Wish {
+func (s *Storage) GetWishes(userId string, status WishStatus) []Wish {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	switch status {
-	case "":
-
-		return copyWishes(s.wishes[userId])
-
-	case "active":
-		return copyWishes(s.wishes[userId])
-
-	case "completed":
-		return copyWishes(s.completed[userId])
-
-	case "canceled":
-		return copyWishes(s.canceled[userId])
+	if status == "" {
+		status = StatusActive
+	}
 
-	default:
-		return []Wish{}
+	out := []Wish{}
+	for _, w := range s.wishes[userId] {
+		if w.Status == status {
+			out = append(out, w)
+		}
 	}
+	return out
+}
+
+// QueryWishes возвращает страницу желаний пользователя с фильтрами и сортировкой
+func (s *Storage) QueryWishes(userId string, q WishQuery) (WishPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return runWishQuery(s.wishes[userId], q)
 }
 
 // AddWish добавляет новое желание пользователя
-// @Summary Добавить желание
-// @Description Добавляет новое желание пользователя
-// @Tags wishes
-// @Param userId path string true "ID пользователя"
-// @Param wish body Wish true "Желание"
-// @Success 200 {string} string "успешно добавлено"
-// @Router /users/{userId}/wishes [post]
-func (s *Storage) AddWish(userId string, w Wish) {
+func (s *Storage) AddWish(ctx context.Context, userId string, w Wish) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	profile := s.profiles[userId]
-	w.ComfortMonths = CalculateComfortMonths(profile, w.Price)
+	w.ComfortMonths = CalculateComfortMonths(profile, w.BasePrice())
 
 	now := time.Now()
 	w.CreatedAt = now
 	w.UpdateAt = now
 
 	if w.Status == "" {
-		w.Status = "active"
+		w.Status = StatusActive
 	}
 
 	s.wishes[userId] = append([]Wish{w}, s.wishes[userId]...)
-	log.Printf("[Storage] Added wish (%s) for user %s: %+v", w.ID, userId, w)
+	metrics.WishEvent("created", 1)
+	storageLog().InfoContext(ctx, "wish added", "user_id", userId, "wish_id", w.ID)
+}
+
+// AddWishes атомарно добавляет пачку желаний пользователя
+func (s *Storage) AddWishes(ctx context.Context, userId string, batch []Wish) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile := s.profiles[userId]
+	now := time.Now()
+
+	added := make([]Wish, 0, len(batch))
+	for i := len(batch) - 1; i >= 0; i-- {
+		w := batch[i]
+		w.ComfortMonths = CalculateComfortMonths(profile, w.BasePrice())
+		w.CreatedAt = now
+		w.UpdateAt = now
+		if w.Status == "" {
+			w.Status = StatusActive
+		}
+		added = append(added, w)
+	}
+
+	s.wishes[userId] = append(added, s.wishes[userId]...)
+	metrics.WishEvent("created", len(added))
+	storageLog().InfoContext(ctx, "wishes imported", "user_id", userId, "count", len(added))
 }
 
 // ToggleStillWant переключает статус желания
-// @Summary Переключить статус желания
-// @Description Переключает статус StillWant для желания по его ID
-// @Tags wishes
-// @Param userId path string true "ID пользователя"
-// @Param wishId path string true "ID желания"
-// @Success 200 {string} string "успешно обновлено"
-// @Router /users/{userId}/wishes/{wishId}/toggle [post]
-func (s *Storage) ToggleStillWant(userId, wishId string) bool {
+func (s *Storage) ToggleStillWant(ctx context.Context, userId, wishId string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -109,62 +494,183 @@ func (s *Storage) ToggleStillWant(userId, wishId string) bool {
 			list[i].StillWant = !list[i].StillWant
 			list[i].UpdateAt = time.Now()
 			s.wishes[userId] = list
-			log.Printf("[Storage] Toggled StillWant for wish %s (%v)", wishId, list[i].StillWant)
+			storageLog().InfoContext(ctx, "still want toggled", "user_id", userId, "wish_id", wishId, "still_want", list[i].StillWant)
 			return true
 		}
 	}
 	return false
 }
 
-// UpdateWishStatus обновляет статус желания
-// @Summary Обновить статус желания
-// @Description Обновляет статус желания (active, completed, canceled)
-// @Tags wishes
-// @Param userId path string true "ID пользователя"
-// @Param wishId path string true "ID желания"
-// @Param status path string true "Новый статус желания"
-// @Success 200 {string} string "успешно обновлено"
-// @Router /users/{userId}/wishes/{wishId}/status [put]
-func (s *Storage) UpdateWishStatus(userId, wishId, status string) bool {
+// UpdateWishStatus обновляет статус желания; при возврате в активные охлаждение
+// и комфорт пересчитываются по текущим настройкам и профилю
+func (s *Storage) UpdateWishStatus(ctx context.Context, userId, wishId string, status WishStatus) (Wish, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.wishes[userId]
+	for i := range list {
+		if list[i].ID != wishId {
+			continue
+		}
+
+		now := time.Now()
+		if err := checkTransition(list[i], status, now); err != nil {
+			return Wish{}, err
+		}
+
+		if status == StatusActive {
+			// пока желание было закрыто, настройки, профиль и время могли измениться:
+			// охлаждение и комфорт считаются заново, а охлаждение начинается с момента возврата
+			set := s.settings[userId]
+			list[i].RecommendedCooling = calcRecommendedCooling(list[i].BasePrice(), list[i].Category, set)
+			list[i].ComfortMonths = CalculateComfortMonths(s.profiles[userId], list[i].BasePrice())
+			list[i].CoolingStartedAt = now
+		}
+		list[i].Status = status
+		list[i].StatusChangedAt = now
+		list[i].UpdateAt = now
+
+		metrics.WishEvent(wishEventName(status), 1)
+		storageLog().InfoContext(ctx, "wish status changed", "user_id", userId, "wish_id", wishId, "status", status)
+		return list[i], nil
+	}
+	return Wish{}, errWishNotFound
+}
+
+// errWishNotFound — желание с таким ID не найдено
+var errWishNotFound = errors.New("not found")
+
+// EditWish применяет изменение к желанию пользователя под блокировкой хранилища.
+// Если edit возвращает ошибку, желание остается прежним; если ничего не изменилось,
+// UpdateAt не сдвигается.
+func (s *Storage) EditWish(ctx context.Context, userId, wishId string, edit func(w *Wish) error) (Wish, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for i := range s.wishes[userId] {
-		if s.wishes[userId][i].ID == wishId {
+	list := s.wishes[userId]
+	for i := range list {
+		if list[i].ID != wishId {
+			continue
+		}
+		w := list[i]
+		w.Edits = append([]WishEdit(nil), w.Edits...)
+		if err := edit(&w); err != nil {
+			return Wish{}, err
+		}
+		if reflect.DeepEqual(w, list[i]) {
+			return w, nil
+		}
+		w.UpdateAt = time.Now()
+		list[i] = w
+		storageLog().InfoContext(ctx, "wish edited", "user_id", userId, "wish_id", wishId)
+		return w, nil
+	}
+	return Wish{}, errWishNotFound
+}
 
-			w := s.wishes[userId][i]
-			w.Status = status
-			w.UpdateAt = time.Now()
+// errWishChanged — пока наблюдатель загружал цену, желание закрыли или сменили ему ссылку
+var errWishChanged = errors.New("wish changed while its price was fetched")
 
-			// убираем из активного списка
-			s.wishes[userId] = append(
-				s.wishes[userId][:i],
-				s.wishes[userId][i+1:]...,
-			)
+// RepriceWish применяет новую цену, найденную наблюдателем цен по ссылке link, под блокировкой хранилища.
+// Цена загружается без блокировки, поэтому желание, которое с тех пор перестало быть активным
+// или получило другую ссылку, не меняется: возвращается errWishChanged.
+// В отличие от EditWish это не правка пользователя: история изменений и UpdateAt не трогаются.
+func (s *Storage) RepriceWish(ctx context.Context, userId, wishId, link string, reprice func(w *Wish) error) (Wish, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-			if status == "completed" {
-				s.completed[userId] = append(s.completed[userId], w)
-			}
-			if status == "canceled" {
-				s.canceled[userId] = append(s.canceled[userId], w)
-			}
+	list := s.wishes[userId]
+	for i := range list {
+		if list[i].ID != wishId {
+			continue
+		}
+		if list[i].Status != StatusActive || list[i].URL != link {
+			return Wish{}, errWishChanged
+		}
+		w := list[i]
+		if err := reprice(&w); err != nil {
+			return Wish{}, err
+		}
+		list[i] = w
+		storageLog().InfoContext(ctx, "wish repriced", "user_id", userId, "wish_id", wishId)
+		return w, nil
+	}
+	return Wish{}, errWishNotFound
+}
 
-			log.Printf("[Storage] Wish %s set to %s", wishId, status)
-			return true
+// FindDuplicates ищет среди желаний пользователя возможные дубли wish
+func (s *Storage) FindDuplicates(userId string, wish Wish) []DuplicateMatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return findDuplicates(wish, s.wishes[userId], time.Now())
+}
+
+// MergeWishes объединяет активные дубли с активным желанием wishId и удаляет их.
+// Результат проверяется validateWish, чтобы объединенное желание можно было дальше править.
+func (s *Storage) MergeWishes(ctx context.Context, userId, wishId string, duplicateIds []string) (Wish, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remove := make(map[string]bool, len(duplicateIds))
+	for _, id := range duplicateIds {
+		if id == wishId {
+			return Wish{}, errMergeSelf
 		}
+		remove[id] = true
 	}
-	return false
+
+	list := s.wishes[userId]
+	target := -1
+	var dups []Wish
+	for i, w := range list {
+		switch {
+		case w.ID == wishId:
+			target = i
+		case remove[w.ID]:
+			dups = append(dups, w)
+		}
+	}
+	if target < 0 || len(dups) != len(remove) {
+		return Wish{}, errWishNotFound
+	}
+	if list[target].Status != StatusActive {
+		return Wish{}, errMergeInactive
+	}
+	for _, d := range dups {
+		if d.Status != StatusActive {
+			return Wish{}, errMergeInactive
+		}
+	}
+
+	now := time.Now()
+	merged := list[target]
+	merged.Edits = append([]WishEdit(nil), merged.Edits...)
+	mergeWishes(&merged, dups, now)
+	merged.UpdateAt = now
+	if err := validateWish(merged, s.profiles[userId]); err != nil {
+		return Wish{}, fmt.Errorf("%w: %w", errMergeInvalid, err)
+	}
+
+	newList := make([]Wish, 0, len(list)-len(dups))
+	for _, w := range list {
+		switch {
+		case w.ID == wishId:
+			newList = append(newList, merged)
+		case !remove[w.ID]:
+			newList = append(newList, w)
+		}
+	}
+	s.wishes[userId] = newList
+	for id := range remove {
+		delete(s.priceHistory, userId+"/"+id)
+	}
+
+	storageLog().InfoContext(ctx, "duplicates merged", "user_id", userId, "wish_id", wishId, "count", len(dups))
+	return merged, nil
 }
 
 // RemoveWish удаляет желание по его ID
-// @Summary Удалить желание
-// @Description Удаляет желание пользователя по его ID
-// @Tags wishes
-// @Param userId path string true "ID пользователя"
-// @Param wishId path string true "ID желания"
-// @Success 200 {string} string "успешно удалено"
-// @Router /users/{userId}/wishes/{wishId} [delete]
-func (s *Storage) RemoveWish(userId, wishId string) bool {
+func (s *Storage) RemoveWish(ctx context.Context, userId, wishId string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -182,19 +688,14 @@ func (s *Storage) RemoveWish(userId, wishId string) bool {
 	s.wishes[userId] = newList
 
 	if found {
-		log.Printf("[Storage] Removed wish %s for user %s", wishId, userId)
+		delete(s.priceHistory, userId+"/"+wishId)
+		storageLog().InfoContext(ctx, "wish removed", "user_id", userId, "wish_id", wishId)
 	}
 
 	return found
 }
 
 // GetSettings возвращает настройки пользователя
-// @Summary Получить настройки пользователя
-// @Description Возвращает настройки пользователя по его ID
-// @Tags settings
-// @Param userId path string true "ID пользователя"
-// @Success 200 {object} Settings
-// @Router /users/{userId}/settings [get]
 func (s *Storage) GetSettings(userId string) Settings {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -202,27 +703,84 @@ func (s *Storage) GetSettings(userId string) Settings {
 }
 
 // SaveSettings сохраняет настройки пользователя
-// @Summary Сохранить настройки пользователя
-// @Description Сохраняет настройки пользователя по его ID
-// @Tags settings
-// @Param userId path string true "ID пользователя"
-// @Param settings body Settings true "Настройки"
-// @Success 200 {string} string "успешно сохранено"
-// @Router /users/{userId}/settings [post]
-func (s *Storage) SaveSettings(userId string, set Settings) {
+func (s *Storage) SaveSettings(ctx context.Context, userId string, set Settings) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.saveSettingsLocked(ctx, userId, set, 0)
+}
+
+// saveSettingsLocked сохраняет настройки, добавляет версию в историю, если они изменились,
+// и пересчитывает рекомендуемое охлаждение активных желаний
+func (s *Storage) saveSettingsLocked(ctx context.Context, userId string, set Settings, rolledBackFrom int) {
+	set = s.secrets.sealSettings(s.settings[userId], set)
+	if changes := diffConfig(s.settings[userId], set); len(changes) > 0 {
+		history := s.settingsHistory[userId]
+		v := SettingsVersion{Version: 1, At: time.Now(), Changes: changes, RolledBackFrom: rolledBackFrom, Settings: set}
+		if n := len(history); n > 0 {
+			v.Version = history[n-1].Version + 1
+		}
+		s.settingsHistory[userId] = trimHistory(append(history, v))
+	}
 	s.settings[userId] = set
-	log.Printf("[Storage] Saved settings for %s: %v\n", userId, set)
+	s.recalcCoolingLocked(userId, set)
+	storageLog().InfoContext(ctx, "settings saved", "user_id", userId)
+}
+
+// recalcCoolingLocked пересчитывает рекомендуемое охлаждение активных желаний по настройкам set
+func (s *Storage) recalcCoolingLocked(userId string, set Settings) {
+	list := s.wishes[userId]
+	for i := range list {
+		if list[i].Status == StatusActive {
+			list[i].RecommendedCooling = calcRecommendedCooling(list[i].BasePrice(), list[i].Category, set)
+		}
+	}
+}
+
+// UpdateSettings изменяет настройки под блокировкой хранилища.
+// Если update возвращает ошибку, настройки остаются прежними.
+func (s *Storage) UpdateSettings(ctx context.Context, userId string, update func(Settings) (Settings, error)) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := update(s.settings[userId])
+	if err != nil {
+		return Settings{}, err
+	}
+	s.saveSettingsLocked(ctx, userId, set, 0)
+	return s.settings[userId], nil
+}
+
+// SettingsHistory возвращает копию истории настроек пользователя
+func (s *Storage) SettingsHistory(userId string) []SettingsVersion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SettingsVersion{}, s.settingsHistory[userId]...)
+}
+
+// RollbackSettings восстанавливает версию настроек как новую версию
+func (s *Storage) RollbackSettings(ctx context.Context, userId string, version int) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.settingsHistory[userId] {
+		if v.Version != version {
+			continue
+		}
+		s.saveSettingsLocked(ctx, userId, v.Settings, version)
+		return v.Settings, nil
+	}
+	return Settings{}, errVersionNotFound
+}
+
+// trimHistory оставляет последние maxConfigVersions версий
+func trimHistory[T any](history []T) []T {
+	if len(history) > maxConfigVersions {
+		history = append([]T(nil), history[len(history)-maxConfigVersions:]...)
+	}
+	return history
 }
 
 // GetProfile возвращает профиль пользователя
-// @Summary Получить профиль пользователя
-// @Description Возвращает профиль по нику пользователя
-// @Tags profile
-// @Param nick path string true "Ник пользователя"
-// @Success 200 {object} UserProfile
-// @Router /users/{nick}/profile [get]
 func (s *Storage) GetProfile(nick string) (UserProfile, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -231,24 +789,168 @@ func (s *Storage) GetProfile(nick string) (UserProfile, bool) {
 }
 
 // SaveProfile сохраняет профиль пользователя
-// @Summary Сохранить профиль пользователя
-// @Description Сохраняет профиль по нику пользователя
-// @Tags profile
-// @Param nick path string true "Ник пользователя"
-// @Param profile body UserProfile true "Объект профиля"
-// @Success 200 {string} string "успешно сохранено"
-// @Router /users/{nick}/profile [post]
-func (s *Storage) SaveProfile(nick string, p UserProfile) {
+func (s *Storage) SaveProfile(ctx context.Context, nick string, p UserProfile) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	p.Nick = nick
+	s.saveProfileLocked(ctx, nick, p, 0)
+}
+
+// saveProfileLocked сохраняет профиль, добавляет версию в историю и пересчитывает желания.
+// При смене валюты в новую валюту переводятся желания всех статусов и диапазоны охлаждения.
+func (s *Storage) saveProfileLocked(ctx context.Context, nick string, p UserProfile, rolledBackFrom int) {
+	p.Nick = s.nickLocked(nick)
+	p.Currency = profileCurrency(p)
+	prevCurrency := profileCurrency(s.profiles[nick])
+	if changes := diffConfig(s.profiles[nick], p); len(changes) > 0 {
+		history := s.profileHistory[nick]
+		v := ProfileVersion{Version: 1, At: time.Now(), Changes: changes, RolledBackFrom: rolledBackFrom, Profile: p}
+		if n := len(history); n > 0 {
+			v.Version = history[n-1].Version + 1
+		}
+		s.profileHistory[nick] = trimHistory(append(history, v))
+	}
 	s.profiles[nick] = p
 
+	if prevCurrency != p.Currency && len(s.settings[nick].Cooldowns) > 0 {
+		set := s.settings[nick]
+		cooldowns, err := convertCooldowns(set.Cooldowns, prevCurrency, p.Currency, s.rates)
+		if err != nil {
+			storageLog().WarnContext(ctx, "can't convert cooldowns", "user_id", nick, "currency", p.Currency, "error", err)
+		} else {
+			set.Cooldowns = cooldowns
+			s.saveSettingsLocked(ctx, nick, set, 0)
+		}
+	}
+
+	// закрытые желания тоже переводятся: при возврате в активные их цена должна быть в валюте профиля
+	settings := s.settings[nick]
 	list := s.wishes[nick]
 	for i := range list {
-		list[i].ComfortMonths = CalculateComfortMonths(p, list[i].Price)
+		if list[i].BaseCurrency != p.Currency {
+			if err := convertWish(&list[i], p.Currency, s.rates); err != nil {
+				storageLog().WarnContext(ctx, "can't convert wish", "user_id", nick, "wish_id", list[i].ID, "currency", p.Currency, "error", err)
+			}
+		}
+		if list[i].Status == StatusActive {
+			list[i].RecommendedCooling = calcRecommendedCooling(list[i].BasePrice(), list[i].Category, settings)
+		}
+		list[i].ComfortMonths = CalculateComfortMonths(p, list[i].BasePrice())
 	}
 	s.wishes[nick] = list
 
-	log.Printf("[Storage] Saved profile for %s: %+v\n", nick, p)
+	storageLog().InfoContext(ctx, "profile saved", "user_id", nick)
+}
+
+// UpdateProfile изменяет профиль под блокировкой хранилища.
+// Если update возвращает ошибку, профиль остается прежним.
+func (s *Storage) UpdateProfile(ctx context.Context, nick string, update func(UserProfile) (UserProfile, error)) (UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := update(s.profiles[nick])
+	if err != nil {
+		return UserProfile{}, err
+	}
+	s.saveProfileLocked(ctx, nick, p, 0)
+	return s.profiles[nick], nil
+}
+
+// ProfileHistory возвращает копию истории профиля пользователя
+func (s *Storage) ProfileHistory(nick string) []ProfileVersion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ProfileVersion{}, s.profileHistory[nick]...)
+}
+
+// RollbackProfile восстанавливает версию профиля как новую версию
+// и пересчитывает валюту и комфорт активных желаний
+func (s *Storage) RollbackProfile(ctx context.Context, nick string, version int) (UserProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.profileHistory[nick] {
+		if v.Version == version {
+			s.saveProfileLocked(ctx, nick, v.Profile, version)
+			return s.profiles[nick], nil
+		}
+	}
+	return UserProfile{}, errVersionNotFound
+}
+
+// CalendarToken возвращает токен iCalendar-ленты пользователя
+func (s *Storage) CalendarToken(userId string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.calendarTokens[userId]
+	return t, ok
+}
+
+// SetCalendarToken выдает пользователю новый токен ленты, старый перестает работать
+func (s *Storage) SetCalendarToken(ctx context.Context, userId, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calendarTokens[userId] = token
+	storageLog().InfoContext(ctx, "calendar token issued", "user_id", userId)
+}
+
+// UserByCalendarToken находит пользователя по токену ленты
+func (s *Storage) UserByCalendarToken(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userId, t := range s.calendarTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return userId, true
+		}
+	}
+	return "", false
+}
+
+// watchedWish — желание со ссылкой, цену которого нужно перепроверять
+type watchedWish struct {
+	UserID string
+	Wish   Wish
+}
+
+// WatchedWishes возвращает активные желания всех пользователей, у которых есть ссылка на товар
+func (s *Storage) WatchedWishes() []watchedWish {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []watchedWish
+	for userId, list := range s.wishes {
+		for _, w := range list {
+			if w.Status == StatusActive && w.URL != "" {
+				out = append(out, watchedWish{UserID: userId, Wish: w})
+			}
+		}
+	}
+	return out
+}
+
+// RecordPrice добавляет наблюдение цены в историю желания
+func (s *Storage) RecordPrice(userId, wishId string, p PricePoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := userId + "/" + wishId
+	history := append(s.priceHistory[key], p)
+	if len(history) > maxPriceHistory {
+		history = history[len(history)-maxPriceHistory:]
+	}
+	s.priceHistory[key] = history
+}
+
+// PriceHistory возвращает историю цены желания; false — если такого желания нет
+func (s *Storage) PriceHistory(userId, wishId string) ([]PricePoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.wishes[userId] {
+		if w.ID == wishId {
+			out := make([]PricePoint, len(s.priceHistory[userId+"/"+wishId]))
+			copy(out, s.priceHistory[userId+"/"+wishId])
+			return out, true
+		}
+	}
+	return nil, false
 }
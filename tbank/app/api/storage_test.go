@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEditWishWithoutChangesKeepsUpdateAt(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Книга", Price: 500})
+	before := storage.GetWishes("u1", StatusActive)[0]
+	rates := storage.Rates()
+
+	time.Sleep(time.Millisecond)
+	title := "Книга"
+	got, err := storage.EditWish(ctx, "u1", "w1", func(w *Wish) error {
+		return applyWishPatch(w, WishPatch{Title: &title}, Settings{}, UserProfile{}, rates)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.UpdateAt.Equal(before.UpdateAt) || len(got.Edits) != 0 {
+		t.Errorf("no-op edit changed the wish: updateAt %v -> %v, edits %d", before.UpdateAt, got.UpdateAt, len(got.Edits))
+	}
+
+	title = "Другая книга"
+	got, err = storage.EditWish(ctx, "u1", "w1", func(w *Wish) error {
+		return applyWishPatch(w, WishPatch{Title: &title}, Settings{}, UserProfile{}, rates)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.UpdateAt.After(before.UpdateAt) || len(got.Edits) != 1 {
+		t.Errorf("edit was not recorded: updateAt %v -> %v, edits %d", before.UpdateAt, got.UpdateAt, len(got.Edits))
+	}
+}
+
+func TestSettingsWritesRecalculateCooling(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Велосипед", Price: 40000, Status: StatusActive})
+	cooling := func() int { return storage.GetWishes("u1", StatusActive)[0].RecommendedCooling }
+
+	storage.SaveSettings(ctx, "u1", Settings{Cooldowns: []CooldownRange{{Min: 0, Period: 3, Unbounded: true}}})
+	if got := cooling(); got != 3 {
+		t.Errorf("after SaveSettings cooling = %d, want 3", got)
+	}
+
+	_, err := storage.UpdateSettings(ctx, "u1", func(set Settings) (Settings, error) {
+		set.Cooldowns = []CooldownRange{{Min: 0, Period: 21, Unbounded: true}}
+		return set, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cooling(); got != 21 {
+		t.Errorf("after UpdateSettings cooling = %d, want 21", got)
+	}
+
+	if _, err := storage.RollbackSettings(ctx, "u1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := cooling(); got != 3 {
+		t.Errorf("after RollbackSettings cooling = %d, want 3", got)
+	}
+}
+
+func TestReopenRecalculatesCooling(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.SaveSettings(ctx, "u1", Settings{Cooldowns: []CooldownRange{{Min: 0, Period: 3, Unbounded: true}}})
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Велосипед", Price: 40000, Status: StatusActive, RecommendedCooling: 3})
+	if _, err := storage.UpdateWishStatus(ctx, "u1", "w1", StatusCompleted); err != nil {
+		t.Fatal(err)
+	}
+
+	storage.SaveSettings(ctx, "u1", Settings{Cooldowns: []CooldownRange{{Min: 0, Period: 14, Unbounded: true}}})
+	storage.SaveProfile(ctx, "u1", UserProfile{MonthlySavingProfile: 10000})
+	before := time.Now()
+	got, err := storage.UpdateWishStatus(ctx, "u1", "w1", StatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RecommendedCooling != 14 || got.ComfortMonths != 4 {
+		t.Errorf("reopened wish: cooling %d, comfort %d; want 14 and 4", got.RecommendedCooling, got.ComfortMonths)
+	}
+	if got.CoolingStartedAt.Before(before) {
+		t.Errorf("cooling started at %v, want restart on reopen", got.CoolingStartedAt)
+	}
+}
+
+func TestProfileCurrencyChangeConvertsCooldownsAndClosedWishes(t *testing.T) {
+	storage := NewStorage()
+	ctx := context.Background()
+	storage.SaveSettings(ctx, "u1", Settings{Cooldowns: []CooldownRange{
+		{Min: 0, Max: 9200, Period: 3},
+		{Min: 9201, Period: 30, Unbounded: true},
+	}})
+	storage.AddWish(ctx, "u1", Wish{ID: "w1", Title: "Наушники", Price: 50, Currency: "USD"})
+	storage.AddWish(ctx, "u1", Wish{ID: "w2", Title: "Колонка", Price: 9000, Currency: "RUB"})
+	if _, err := storage.UpdateWishStatus(ctx, "u1", "w2", StatusCanceled); err != nil {
+		t.Fatal(err)
+	}
+
+	storage.SaveProfile(ctx, "u1", UserProfile{Currency: "USD"})
+
+	got := storage.GetSettings("u1").Cooldowns
+	if len(got) != 2 || got[0].Max != 100 || got[1].Min != 101 || !got[1].Unbounded {
+		t.Fatalf("cooldowns = %+v, want bounds converted to USD", got)
+	}
+	if errs := validateCooldowns(got); len(errs) > 0 {
+		t.Errorf("converted cooldowns are invalid: %+v", errs)
+	}
+	active := storage.GetWishes("u1", StatusActive)[0]
+	if active.BaseCurrency != "USD" || active.RecommendedCooling != 3 {
+		t.Errorf("active wish: base %s, cooling %d; want USD and 3", active.BaseCurrency, active.RecommendedCooling)
+	}
+	canceled := storage.GetWishes("u1", StatusCanceled)[0]
+	if canceled.BaseCurrency != "USD" || canceled.ConvertedPrice != 97.83 {
+		t.Errorf("canceled wish: base %s, converted %v; want USD and 97.83", canceled.BaseCurrency, canceled.ConvertedPrice)
+	}
+}
+
+func TestStoragePing(t *testing.T) {
+	storage := NewStorage()
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	storage.mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := storage.Ping(ctx)
+	storage.mu.Unlock()
+	if !errors.Is(err, errStorageUnavailable) {
+		t.Errorf("ping of a locked storage = %v, want errStorageUnavailable", err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errTOMLUnsupported — конструкция TOML, которую parseTOML не разбирает
+var errTOMLUnsupported = errors.New("unsupported TOML syntax")
+
+// tomlBareKey — ключ TOML без кавычек
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseTOML разбирает подмножество TOML, которого хватает файлу настроек: таблицы [name]
+// и [a.b], пары key = value, строки в двойных и одинарных кавычках, целые и дробные числа,
+// true/false и однострочные массивы. Многострочные строки и массивы, встроенные таблицы,
+// массивы таблиц, даты и точечные ключи в парах дают errTOMLUnsupported.
+func parseTOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	cur := root
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		lineErr := func(err error) error { return fmt.Errorf("line %d: %w", n+1, err) }
+
+		if line[0] == '[' {
+			if strings.HasPrefix(line, "[[") {
+				return nil, lineErr(fmt.Errorf("%w: arrays of tables", errTOMLUnsupported))
+			}
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, lineErr(errors.New("table header is not closed"))
+			}
+			if err := tomlLineEnd(line[end+1:]); err != nil {
+				return nil, lineErr(err)
+			}
+			cur = root
+			for _, part := range strings.Split(line[1:end], ".") {
+				name, err := tomlKey(part)
+				if err != nil {
+					return nil, lineErr(err)
+				}
+				next, ok := cur[name].(map[string]any)
+				if !ok {
+					if _, exists := cur[name]; exists {
+						return nil, lineErr(fmt.Errorf("key %q is already defined", name))
+					}
+					next = map[string]any{}
+					cur[name] = next
+				}
+				cur = next
+			}
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, lineErr(errors.New("expected key = value"))
+		}
+		key, err := tomlKey(line[:eq])
+		if err != nil {
+			return nil, lineErr(err)
+		}
+		v, rest, err := parseTOMLValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, lineErr(err)
+		}
+		if err := tomlLineEnd(rest); err != nil {
+			return nil, lineErr(err)
+		}
+		if _, exists := cur[key]; exists {
+			return nil, lineErr(fmt.Errorf("key %q is already defined", key))
+		}
+		cur[key] = v
+	}
+	return root, nil
+}
+
+// tomlKey разбирает ключ: без кавычек или в двойных кавычках
+func tomlKey(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, `"`) {
+		return strconv.Unquote(raw)
+	}
+	if strings.Contains(raw, ".") {
+		return "", fmt.Errorf("%w: dotted key %q", errTOMLUnsupported, raw)
+	}
+	if !tomlBareKey.MatchString(raw) {
+		return "", fmt.Errorf("invalid key %q", raw)
+	}
+	return raw, nil
+}
+
+// tomlLineEnd проверяет, что после значения остался только комментарий
+func tomlLineEnd(rest string) error {
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return fmt.Errorf("unexpected %q", rest)
+	}
+	return nil
+}
+
+// parseTOMLValue разбирает значение в начале s и возвращает остаток строки
+func parseTOMLValue(s string) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", errors.New("value is missing")
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return nil, "", fmt.Errorf("%w: multi-line strings", errTOMLUnsupported)
+	case s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				return v, s[i+1:], err
+			}
+		}
+		return nil, "", errors.New("string is not closed")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", errors.New("string is not closed")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '[':
+		return parseTOMLArray(s)
+	case s[0] == '{':
+		return nil, "", fmt.Errorf("%w: inline tables", errTOMLUnsupported)
+	}
+
+	end := strings.IndexAny(s, ",]# \t")
+	if end < 0 {
+		end = len(s)
+	}
+	tok, rest := s[:end], s[end:]
+	switch tok {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	if i, err := strconv.ParseInt(tok, 0, 64); err == nil {
+		return i, rest, nil
+	}
+	if f, err := strconv.ParseFloat(strings.ReplaceAll(tok, "_", ""), 64); err == nil {
+		return f, rest, nil
+	}
+	return nil, "", fmt.Errorf("%w: value %q", errTOMLUnsupported, tok)
+}
+
+// parseTOMLArray разбирает однострочный массив
+func parseTOMLArray(s string) (any, string, error) {
+	items := []any{}
+	s = strings.TrimSpace(s[1:])
+	for {
+		if s == "" {
+			return nil, "", fmt.Errorf("%w: multi-line arrays", errTOMLUnsupported)
+		}
+		if s[0] == ']' {
+			return items, s[1:], nil
+		}
+		v, rest, err := parseTOMLValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, v)
+		s = strings.TrimSpace(rest)
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("expected , or ] in array, got %q", s)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// userIDPrefix — префикс внутренних ID пользователей; ник с него начинаться не может,
+// поэтому ID и ник в пути не путаются
+const userIDPrefix = "usr_"
+
+var (
+	errUserNotFound = errors.New("user not found")
+	errInvalidNick  = errors.New("nick must be 3-64 letters, digits, '.', '_', '-' or '@' and must not start with " + userIDPrefix)
+	errNickTaken    = errors.New("nick is already taken")
+	errInvalidPhone = errors.New("phone must contain 10-15 digits")
+	errPhoneTaken   = errors.New("phone is already used by another user")
+)
+
+// nickPattern — допустимый ник; '@' разрешен, чтобы старые userId-почты оставались валидными
+var nickPattern = regexp.MustCompile(`^[\p{L}\p{N}._@-]{3,64}$`)
+
+// User представляет учетную запись пользователя.
+// @Description Пользователь: постоянный ID, ник и телефон.
+type User struct {
+	// ID — постоянный внутренний ID, не меняется при смене ника
+	// example: "usr_dm8fg9fkkuwa621"
+	ID string `json:"id"`
+	// Nick — уникальный ник без учета регистра
+	// example: "TestMeowUser"
+	Nick string `json:"nick"`
+	// Phone — телефон в формате +79991234567
+	// example: "+79991234567"
+	Phone string `json:"phone,omitempty"`
+	// CreatedAt — дата регистрации
+	CreatedAt time.Time `json:"createdAt"`
+	// PINEnabled — вход по нику защищен PIN-кодом
+	PINEnabled bool `json:"pinEnabled"`
+
+	// pinHash, pinSalt — PBKDF2 от PIN; наружу не отдаются
+	pinHash []byte
+	pinSalt []byte
+	// epoch — поколение сессий; увеличивается при смене PIN
+	epoch int
+	// pinFailures, pinLockedUntil — неверные PIN со всех клиентов и блокировка входа по нику
+	pinFailures    int
+	pinLockedUntil time.Time
+}
+
+// pinClientState — неверные PIN к одному нику с одного клиента и блокировка этого клиента
+type pinClientState struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+// pinAttempt — проверка PIN, начатая под блокировкой хранилища. PBKDF2 медленный,
+// поэтому хеш считается без блокировки по копии соли, а сверяется снова под ней.
+type pinAttempt struct {
+	userID string
+	client string
+	pin    string
+	salt   []byte
+}
+
+// UserInput — поля регистрации и изменения пользователя
+// @Description Ник и телефон; при изменении пустые поля не меняются.
+type UserInput struct {
+	// Nick — ник
+	// example: "TestMeowUser"
+	Nick string `json:"nick" validate:"maxlen=64"`
+	// Phone — телефон; "-" при изменении удаляет телефон
+	// example: "+7 999 123-45-67"
+	Phone string `json:"phone" validate:"maxlen=32"`
+}
+
+// nickKey — ключ ника без учета регистра
+func nickKey(nick string) string {
+	return strings.ToLower(strings.TrimSpace(nick))
+}
+
+// validateNick проверяет формат ника
+func validateNick(nick string) error {
+	if !nickPattern.MatchString(nick) || strings.HasPrefix(nickKey(nick), userIDPrefix) {
+		return errInvalidNick
+	}
+	return nil
+}
+
+// normalizePhone приводит телефон к виду +<цифры>; российский номер на 8 переводится в +7
+func normalizePhone(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", errInvalidPhone
+		}
+	}
+	d := digits.String()
+	if len(d) == 11 && d[0] == '8' && !strings.HasPrefix(raw, "+") {
+		d = "7" + d[1:]
+	}
+	if len(d) < 10 || len(d) > 15 {
+		return "", errInvalidPhone
+	}
+	return "+" + d, nil
+}
+
+// identityMiddleware переводит {userId} и {nick} из пути во внутренний ID пользователя,
+// так что обработчики и хранилище работают только с постоянными ID.
+// В пути можно передать и ID, и ник. Пользователи появляются только через POST /api/v1/users
+// и вход /api/v1/session: изменяющий запрос к незарегистрированному нику получает 404,
+// чтобы опечатка в нике не заводила нового пользователя. При чтении неизвестный ник
+// остается как есть, и обработчик отдает пустой результат.
+func identityMiddleware(storage *Storage) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			vars := mux.Vars(r)
+			for _, key := range []string{"userId", "nick"} {
+				ref, ok := vars[key]
+				if !ok {
+					continue
+				}
+				u, ok := storage.ResolveUser(ref)
+				if !ok {
+					if r.Method == http.MethodGet || r.Method == http.MethodHead {
+						continue
+					}
+					writeError(w, r, errUserNotFound)
+					return
+				}
+				vars[key] = u.ID
+				setRequestUser(r.Context(), u.ID)
+			}
+			next.ServeHTTP(w, mux.SetURLVars(r, vars))
+		})
+	}
+}
+
+// RegisterUserHandler создает обработчик регистрации пользователя
+// @Summary Зарегистрировать пользователя
+// @Description Создает пользователя с уникальным ником и необязательным телефоном
+// @Tags users
+// @Param user body UserInput true "Ник и телефон"
+// @Accept json
+// @Produce json
+// @Success 201 {object} User
+// @Failure 400 {object} Problem "ошибка валидации"
+// @Failure 409 {object} Problem "nick is already taken"
+// @Router /users [post]
+func RegisterUserHandler(storage *Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body UserInput
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+
+		u, err := storage.RegisterUser(r.Context(), body.Nick, body.Phone)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		setRequestUser(r.Context(), u.ID)
+		logger("handler").InfoContext(r.Context(), "user registered", "user_id", u.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(u)
+	}
+}
+
+// GetUserHandler создает обработчик поиска пользователя
+// @Summary Получить пользователя
+// @Description Находит пользователя по ID или нику (без учета регистра). Телефон виден только самому пользователю.
+// @Tags users
+// @Param ref path string true "ID или ник"
+// @Produce json
+// @Success 200 {object} User
+// @Failure 404 {object} Problem "user not found"
+// @Router /users/{ref} [get]
+func GetUserHandler(storage *Storage, sessions *SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := storage.ResolveUser(mux.Vars(r)["ref"])
+		if !ok {
+			writeError(w, r, errUserNotFound)
+			return
+		}
+		if uid, err := sessions.SessionUser(r); err != nil || uid != u.ID {
+			u.Phone = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	}
+}
+
+// UpdateUserHandler создает обработчик смены ника и телефона
+// @Summary Изменить ник или телефон
+// @Description Меняет ник и/или телефон. Желания, настройки и профиль привязаны к ID и сохраняются.
+// @Tags users
+// @Param ref path string true "ID или ник"
+// @Param user body UserInput true "Новые ник и телефон"
+// @Accept json
+// @Produce json
+// @Success 200 {object} User
+// @Failure 403 {object} Problem "session belongs to another user"
+// @Failure 404 {object} Problem "user not found"
+// @Failure 409 {object} Problem "nick is already taken"
+// @Security SessionToken
+// @Router /users/{ref} [patch]
+func UpdateUserHandler(storage *Storage, sessions *SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref := mux.Vars(r)["ref"]
+		if u, ok := storage.ResolveUser(ref); !ok {
+			writeError(w, r, errUserNotFound)
+			return
+		} else if err := sessions.Authorize(r, u.ID); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		var body UserInput
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+
+		u, err := storage.UpdateUser(r.Context(), ref, body)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		logger("handler").InfoContext(r.Context(), "user updated", "user_id", u.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestIdentityMiddleware(t *testing.T) {
+	storage := NewStorage()
+	u, err := storage.RegisterUser(context.Background(), "Cat", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotUserId string
+	r := mux.NewRouter()
+	r.Use(identityMiddleware(storage))
+	r.HandleFunc("/wishes/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		gotUserId = mux.Vars(r)["userId"]
+	})
+
+	tests := []struct {
+		method, ref string
+		status      int
+		userId      string
+	}{
+		{"POST", "cat", http.StatusOK, u.ID},
+		{"POST", u.ID, http.StatusOK, u.ID},
+		{"GET", "dog", http.StatusOK, "dog"},
+		{"POST", "caat", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		gotUserId = ""
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, "/wishes/"+tt.ref, nil))
+		if rec.Code != tt.status || gotUserId != tt.userId {
+			t.Errorf("%s %s: status %d, userId %q; want %d, %q", tt.method, tt.ref, rec.Code, gotUserId, tt.status, tt.userId)
+		}
+	}
+	if _, ok := storage.ResolveUser("caat"); ok {
+		t.Error("a mutating request registered a mistyped nick")
+	}
+}
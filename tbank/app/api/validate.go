@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBodyBytes — максимальный размер JSON-тела запроса
+	maxBodyBytes = 1 << 20
+	// defaultMaxStringLen — длина строки по умолчанию, если у поля нет правила maxlen
+	defaultMaxStringLen = 1000
+)
+
+// Правила валидации задаются в теге validate через запятую и проверяются validateStruct:
+//
+//	required      — значение задано; строка не пустая после обрезки пробелов
+//	gt=N, gte=N   — число больше (не меньше) N
+//	lte=N         — число не больше N
+//	maxlen=N      — строка не длиннее N символов, срез — не больше N элементов
+//	itemmaxlen=N  — каждая строка среза не длиннее N символов
+//	oneof=a b c   — значение из списка
+//	email         — адрес почты
+//	url           — абсолютная http(s)-ссылка
+//
+// Кроме required, правила для строк и срезов проверяют только непустые значения.
+// Указатели проверяются, если заданы; вложенные структуры и срезы структур — рекурсивно.
+// Каждая строка ограничена defaultMaxStringLen, если у поля нет своего maxlen.
+
+// validationRule — разобранное правило из тега
+type validationRule struct {
+	name  string
+	param string
+}
+
+func parseRules(tag string) []validationRule {
+	var rules []validationRule
+	for _, part := range strings.Split(tag, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		rules = append(rules, validationRule{name: name, param: param})
+	}
+	return rules
+}
+
+// validateStruct проверяет правила из тегов validate у v и вложенных структур.
+// Пути полей строятся по json-именам: cooldowns[0].min.
+func validateStruct(v any) []FieldError {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	var errs []FieldError
+	validateValue(rv, "", nil, &errs)
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, rules []validationRule, errs *[]FieldError) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	empty := (v.Kind() == reflect.String || v.Kind() == reflect.Slice) && v.Len() == 0
+	hasMaxLen := false
+	for _, rule := range rules {
+		if rule.name == "maxlen" {
+			hasMaxLen = true
+		}
+		if !empty || rule.name == "required" {
+			if key, bad := checkRule(v, rule); bad {
+				*errs = append(*errs, ruleError(path, key, rule))
+				return
+			}
+		}
+	}
+	if v.Kind() == reflect.String && !hasMaxLen && len([]rune(v.String())) > defaultMaxStringLen {
+		rule := validationRule{name: "maxlen", param: strconv.Itoa(defaultMaxStringLen)}
+		*errs = append(*errs, ruleError(path, "maxlen", rule))
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if _, ok := v.Interface().(interface{ MarshalJSON() ([]byte, error) }); ok {
+			return
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			validateValue(v.Field(i), name, parseRules(f.Tag.Get("validate")), errs)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			if item.Kind() == reflect.Struct || item.Kind() == reflect.String {
+				validateValue(item, fmt.Sprintf("%s[%d]", path, i), itemRules(rules), errs)
+			}
+		}
+	}
+}
+
+// itemRules превращает itemmaxlen среза в maxlen его элементов
+func itemRules(rules []validationRule) []validationRule {
+	var out []validationRule
+	for _, r := range rules {
+		if r.name == "itemmaxlen" {
+			out = append(out, validationRule{name: "maxlen", param: r.param})
+		}
+	}
+	return out
+}
+
+// ruleError собирает ошибку поля; сообщение — на языке по умолчанию,
+// writeFieldErrors переводит его на язык запроса
+func ruleError(path, key string, rule validationRule) FieldError {
+	return FieldError{
+		Field:   path,
+		Message: localize(defaultLang, key, rule.param, key),
+		Rule:    rule.name,
+		Param:   rule.param,
+		key:     key,
+	}
+}
+
+// checkRule проверяет одно правило и возвращает ключ сообщения из validationMessages
+func checkRule(v reflect.Value, rule validationRule) (string, bool) {
+	switch rule.name {
+	case "required":
+		if v.Kind() == reflect.String {
+			return "required", strings.TrimSpace(v.String()) == ""
+		}
+		return "required", v.IsZero()
+	case "gt", "gte", "lte":
+		n, ok := number(v)
+		limit, _ := strconv.ParseFloat(rule.param, 64)
+		switch {
+		case !ok:
+			return "", false
+		case math.IsNaN(n) || math.IsInf(n, 0):
+			return "finite", true
+		case rule.name == "gt":
+			return "gt", n <= limit
+		case rule.name == "gte":
+			return "gte", n < limit
+		default:
+			return "lte", n > limit
+		}
+	case "maxlen":
+		limit, _ := strconv.Atoi(rule.param)
+		switch v.Kind() {
+		case reflect.String:
+			return "maxlen", len([]rune(v.String())) > limit
+		case reflect.Slice, reflect.Map:
+			return "maxlen.items", v.Len() > limit
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(rule.param) {
+			if s == opt {
+				return "", false
+			}
+		}
+		return "oneof", true
+	case "email":
+		addr, err := mail.ParseAddress(v.String())
+		return "email", err != nil || addr.Address != strings.TrimSpace(v.String())
+	case "url":
+		return "url", validateWishURL(strings.TrimSpace(v.String())) != nil
+	}
+	return "", false
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return float64(v.Int()), true
+	case reflect.Float64, reflect.Float32:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// decodeJSON читает тело запроса (не больше maxBodyBytes) в v и проверяет правила validate.
+// При ошибке отвечает problem+json и возвращает false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeDecodeError(w, r, err)
+		return false
+	}
+	if errs := validateStruct(v); len(errs) > 0 {
+		writeFieldErrors(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// writeDecodeError отвечает на ошибку чтения JSON: 413 для слишком большого тела, иначе 400 invalid_json
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, err)
+		return
+	}
+	writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+}
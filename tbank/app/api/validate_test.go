@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+type validateItem struct {
+	Min float64 `json:"min" validate:"gte=0"`
+}
+
+type validateSample struct {
+	Title    string         `json:"title" validate:"required,maxlen=5"`
+	Price    float64        `json:"price" validate:"gt=0,lte=100"`
+	Priority string         `json:"priority" validate:"oneof=low high"`
+	Email    string         `json:"email" validate:"email"`
+	Link     string         `json:"link" validate:"url"`
+	Tags     []string       `json:"tags" validate:"maxlen=2,itemmaxlen=3"`
+	Count    *int           `json:"count" validate:"lte=10"`
+	Items    []validateItem `json:"items"`
+	Notes    string         `json:"notes"`
+	Hidden   string         `json:"-" validate:"required"`
+}
+
+func validSample() validateSample {
+	return validateSample{Title: "Кот", Price: 10, Priority: "low", Email: "cat@example.com", Link: "https://example.com"}
+}
+
+func TestValidateStruct(t *testing.T) {
+	eleven := 11
+	tests := []struct {
+		name  string
+		edit  func(*validateSample)
+		field string
+		rule  string
+	}{
+		{"valid", func(*validateSample) {}, "", ""},
+		{"empty optional fields", func(s *validateSample) { s.Priority, s.Email, s.Link = "", "", "" }, "", ""},
+		{"required blank", func(s *validateSample) { s.Title = "  " }, "title", "required"},
+		{"maxlen counts runes", func(s *validateSample) { s.Title = "Котики" }, "title", "maxlen"},
+		{"gt", func(s *validateSample) { s.Price = 0 }, "price", "gt"},
+		{"lte", func(s *validateSample) { s.Price = 100.5 }, "price", "lte"},
+		{"nan", func(s *validateSample) { s.Price = math.NaN() }, "price", "gt"},
+		{"oneof", func(s *validateSample) { s.Priority = "urgent" }, "priority", "oneof"},
+		{"email", func(s *validateSample) { s.Email = "Cat <cat@example.com>" }, "email", "email"},
+		{"url", func(s *validateSample) { s.Link = "ftp://example.com" }, "link", "url"},
+		{"slice maxlen", func(s *validateSample) { s.Tags = []string{"a", "b", "c"} }, "tags", "maxlen"},
+		{"itemmaxlen", func(s *validateSample) { s.Tags = []string{"a", "long"} }, "tags[1]", "maxlen"},
+		{"pointer", func(s *validateSample) { s.Count = &eleven }, "count", "lte"},
+		{"nested", func(s *validateSample) { s.Items = []validateItem{{Min: 1}, {Min: -1}} }, "items[1].min", "gte"},
+		{"default maxlen", func(s *validateSample) { s.Notes = strings.Repeat("a", defaultMaxStringLen+1) }, "notes", "maxlen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validSample()
+			tt.edit(&s)
+			errs := validateStruct(&s)
+			if tt.field == "" {
+				if len(errs) != 0 {
+					t.Fatalf("errors = %+v, want none", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want one for %s", errs, tt.field)
+			}
+			if errs[0].Field != tt.field || errs[0].Rule != tt.rule {
+				t.Errorf("error = %s/%s, want %s/%s", errs[0].Field, errs[0].Rule, tt.field, tt.rule)
+			}
+		})
+	}
+}
+
+func TestValidateStructNil(t *testing.T) {
+	var s *validateSample
+	if errs := validateStruct(s); errs != nil {
+		t.Errorf("errors = %+v, want none for nil pointer", errs)
+	}
+}
@@ -1,6 +1,7 @@
 "use client";
 
 import { useEffect, useState } from "react";
+import { apiFetch } from "@/app/session";
 
 type Wish = {
   id: string;
@@ -22,7 +23,7 @@ type Profile = {
   blockedCategories: string[];
 };
 
-const API = "http://localhost:8080/api";
+const API = "http://localhost:8080/api/v1";
 
 function calcComfortPeriod(price: number, total: number, monthly: number) {
   const comfort = 0.5;
@@ -67,7 +68,7 @@ export default function CabinetPage() {
   async function loadProfileAndWishes() {
     setLoading(true);
     try {
-      const pRes = await fetch(`${API}/user/${nick}`);
+      const pRes = await apiFetch(nick, `${API}/user/${nick}`);
       const pjson = await pRes.json();
       setProfile({
         nick: pjson.nick,
@@ -81,12 +82,12 @@ export default function CabinetPage() {
       setMonthlySavingProfile(pjson.monthlySavingProfile || "");
       setBlockedText((pjson.blockedCategories || []).join(", "));
 
-      const wRes = await fetch(`${API}/wishes/${nick}?status=active`);
+      const wRes = await apiFetch(nick, `${API}/wishes/${nick}?status=active`);
       const wjson: Wish[] = await wRes.json();
       setWishes(wjson || []);
 
-      const hRes1 = await fetch(`${API}/wishes/${nick}?status=completed`);
-      const hRes2 = await fetch(`${API}/wishes/${nick}?status=canceled`);
+      const hRes1 = await apiFetch(nick, `${API}/wishes/${nick}?status=completed`);
+      const hRes2 = await apiFetch(nick, `${API}/wishes/${nick}?status=canceled`);
       const h1: Wish[] = await hRes1.json();
       const h2: Wish[] = await hRes2.json();
       setHistory([...(h1 || []), ...(h2 || [])]);
@@ -110,7 +111,7 @@ export default function CabinetPage() {
       blockedCategories: blockedText.split(",").map(s => s.trim()).filter(Boolean),
     };
     try {
-      const res = await fetch(`${API}/user/${nick}`, {
+      const res = await apiFetch(nick, `${API}/user/${nick}`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify(body),
@@ -126,7 +127,7 @@ export default function CabinetPage() {
 
   async function markCompleted(id: string) {
     try {
-      const res = await fetch(`${API}/wishes/${nick}/${id}?action=complete`, { method: "PUT" });
+      const res = await apiFetch(nick, `${API}/wishes/${nick}/${id}?action=complete`, { method: "PUT" });
       if (!res.ok) throw new Error(await res.text());
       loadProfileAndWishes();
     } catch (err) {
@@ -137,7 +138,7 @@ export default function CabinetPage() {
 
   async function markCanceled(id: string) {
     try {
-      const res = await fetch(`${API}/wishes/${nick}/${id}?action=cancel`, { method: "PUT" });
+      const res = await apiFetch(nick, `${API}/wishes/${nick}/${id}?action=cancel`, { method: "PUT" });
       if (!res.ok) throw new Error(await res.text());
       loadProfileAndWishes();
     } catch (err) {
@@ -149,7 +150,7 @@ export default function CabinetPage() {
   async function removeWish(id: string) {
     if (!confirm("Удалить желание?")) return;
     try {
-      const res = await fetch(`${API}/wishes/${nick}/${id}`, { method: "DELETE" });
+      const res = await apiFetch(nick, `${API}/wishes/${nick}/${id}`, { method: "DELETE" });
       if (!res.ok) throw new Error(await res.text());
       loadProfileAndWishes();
     } catch (err) {
@@ -2,6 +2,7 @@
 
 import { useEffect, useState } from "react";
 import { usePathname, useRouter } from "next/navigation";
+import { apiFetch } from "@/app/session";
 
 type Wish = {
   id: string;
@@ -38,9 +39,9 @@ type Profile = {
   blockedCategories: string[];
 };
 
-const WISHES_API = "http://localhost:8080/api/wishes";
-const SETTINGS_API = "http://localhost:8080/api/settings";
-const PROFILE_API = "http://localhost:8080/api/profile";
+const WISHES_API = "http://localhost:8080/api/v1/wishes";
+const SETTINGS_API = "http://localhost:8080/api/v1/settings";
+const PROFILE_API = "http://localhost:8080/api/v1/profile";
 
 export default function HomePage() {
   const [title, setTitle] = useState("");
@@ -61,7 +62,7 @@ export default function HomePage() {
 
   async function fetchSettings() {
     try {
-      const res = await fetch(`${SETTINGS_API}/${userId}`);
+      const res = await apiFetch(userId, `${SETTINGS_API}/${userId}`);
       if (!res.ok) throw new Error(await res.text());
       const data = await res.json();
       setSettings(data);
@@ -74,7 +75,7 @@ export default function HomePage() {
 
   async function fetchProfile() {
     try {
-      const res = await fetch(`http://localhost:8080/api/user/${userId}`);
+      const res = await apiFetch(userId, `http://localhost:8080/api/v1/user/${userId}`);
       if (!res.ok) throw new Error(await res.text());
       const data = await res.json();
 
@@ -95,7 +96,7 @@ export default function HomePage() {
   async function fetchWishes() {
     try {
       setLoading(true);
-      const res = await fetch(`${WISHES_API}/${userId}`);
+      const res = await apiFetch(userId, `${WISHES_API}/${userId}`);
       if (!res.ok) throw new Error(await res.text());
       const data: Wish[] = await res.json();
       setWishes(data);
@@ -136,7 +137,7 @@ export default function HomePage() {
     if (monthlySaving !== "") payload.monthlySaving = Number(monthlySaving);
 
     try {
-      const res = await fetch(`${WISHES_API}/${userId}`, {
+      const res = await apiFetch(userId, `${WISHES_API}/${userId}`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify(payload),
@@ -159,7 +160,7 @@ export default function HomePage() {
   async function removeWish(id: string) {
     if (!confirm("Удалить желание?")) return;
     try {
-      const res = await fetch(`${WISHES_API}/${userId}/${id}`, { method: "DELETE" });
+      const res = await apiFetch(userId, `${WISHES_API}/${userId}/${id}`, { method: "DELETE" });
       if (!res.ok) throw new Error(await res.text());
       fetchWishes();
     } catch (err) {
@@ -0,0 +1,58 @@
+// Сессия API: вход по нику через POST /api/v1/session и токен в заголовке Authorization.
+// API пускает к данным пользователя только с его сессией; новый ник при входе регистрируется.
+
+const SESSION_API = "http://localhost:8080/api/v1/session";
+
+// pending — входы, которые уже идут: параллельные запросы ждут один и тот же
+const pending = new Map<string, Promise<string>>();
+
+function tokenKey(nick: string) {
+  return `sessionToken:${nick.toLowerCase()}`;
+}
+
+async function requestSession(nick: string): Promise<string> {
+  let pin = "";
+  for (;;) {
+    const res = await fetch(SESSION_API, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ nick, pin }),
+    });
+    if (res.ok) {
+      const data = await res.json();
+      localStorage.setItem(tokenKey(nick), data.token);
+      return data.token;
+    }
+    // 401 — у ника включен PIN или он неверный, 429 — слишком много неверных PIN
+    if (res.status !== 401) throw new Error(await res.text());
+    const entered = window.prompt(pin ? "Неверный PIN, попробуйте еще раз" : `Введите PIN для ${nick}`);
+    if (!entered) throw new Error("Вход отменен");
+    pin = entered;
+  }
+}
+
+// signIn входит под ником и сохраняет токен; если у ника включен PIN, спрашивает его
+export function signIn(nick: string): Promise<string> {
+  let p = pending.get(nick);
+  if (!p) {
+    p = requestSession(nick).finally(() => pending.delete(nick));
+    pending.set(nick, p);
+  }
+  return p;
+}
+
+function withToken(init: RequestInit, token: string): RequestInit {
+  const headers = new Headers(init.headers);
+  headers.set("Authorization", `Bearer ${token}`);
+  return { ...init, headers };
+}
+
+// apiFetch выполняет запрос к данным пользователя nick с его токеном.
+// Если токена нет или он отозван (смена PIN, перезапуск сервера), входит заново и повторяет запрос.
+export async function apiFetch(nick: string, url: string, init: RequestInit = {}): Promise<Response> {
+  const token = localStorage.getItem(tokenKey(nick)) || (await signIn(nick));
+  const res = await fetch(url, withToken(init, token));
+  if (res.status !== 401) return res;
+  localStorage.removeItem(tokenKey(nick));
+  return fetch(url, withToken(init, await signIn(nick)));
+}
@@ -1,16 +1,19 @@
 "use client";
 
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import { useRouter } from "next/navigation";
+import { apiFetch } from "@/app/session";
 
 type CooldownRange = {
   min: number | "";
   max: number | "";
   period: number | "";
+  // unbounded — у верхнего диапазона нет потолка
+  unbounded: boolean;
 };
 
 type SettingsPayload = {
-  cooldowns: { min: number; max: number; period: number }[];
+  cooldowns: { min: number; max?: number; period: number; unbounded?: boolean }[];
   notificationFrequency?: string;
   excludedProducts?: string;
   notificationChannel?: string;
@@ -18,17 +21,19 @@ type SettingsPayload = {
   monthlySaving?: number;
 };
 
-const SETTINGS_API = "http://localhost:8080/api/settings";
+const SETTINGS_API = "http://localhost:8080/api/v1/settings";
 
 export default function SettingsPage() {
   const [cooldownRanges, setCooldownRanges] = useState<CooldownRange[]>([
-    { min: "", max: "", period: "" },
+    { min: "", max: "", period: "", unbounded: true },
   ]);
   const [notificationFrequency, setNotificationFrequency] = useState("");
   const [excludedProducts, setExcludedProducts] = useState("");
   const [notificationChannel, setNotificationChannel] = useState("");
   const [totalSpent, setTotalSpent] = useState<number | "">("");
   const [monthlySaving, setMonthlySaving] = useState<number | "">("");
+  // saved — поля страницы в том виде, в каком они сохранены на сервере; сохраняются только отличия
+  const saved = useRef<Partial<SettingsPayload>>({});
   const router = useRouter();
   const userId = "testmeowmeow";
 
@@ -38,7 +43,7 @@ export default function SettingsPage() {
 
   async function loadSettings() {
     try {
-      const res = await fetch(`${SETTINGS_API}/${userId}`);
+      const res = await apiFetch(userId, `${SETTINGS_API}/${userId}`);
       if (!res.ok) {
         console.warn("Нет сохранённых настроек, статус:", res.status);
         return;
@@ -48,8 +53,9 @@ export default function SettingsPage() {
         setCooldownRanges(
           data.cooldowns.map((c: any) => ({
             min: c.min ?? "",
-            max: c.max ?? "",
+            max: c.unbounded ? "" : c.max ?? "",
             period: c.period ?? "",
+            unbounded: Boolean(c.unbounded),
           }))
         );
       }
@@ -58,6 +64,18 @@ export default function SettingsPage() {
       setNotificationChannel(data.notificationChannel || "");
       setTotalSpent(data.totalSpent ?? "");
       setMonthlySaving(data.monthlySaving ?? "");
+      saved.current = {
+        cooldowns: (data.cooldowns ?? []).map((c: any) =>
+          c.unbounded
+            ? { min: c.min, period: c.period, unbounded: true }
+            : { min: c.min, max: c.max, period: c.period }
+        ),
+        notificationFrequency: data.notificationFrequency,
+        excludedProducts: data.excludedProducts,
+        notificationChannel: data.notificationChannel,
+        totalSpent: data.totalSpent,
+        monthlySaving: data.monthlySaving,
+      };
     } catch (err) {
       console.error("Ошибка загрузки настроек:", err);
     }
@@ -71,33 +89,62 @@ export default function SettingsPage() {
     }
   }
 
+  function toggleUnbounded(index: number, value: boolean) {
+    const arr = [...cooldownRanges];
+    arr[index] = { ...arr[index], unbounded: value, max: value ? "" : arr[index].max };
+    setCooldownRanges(arr);
+  }
+
+  // «потолка нет» может быть только у последнего диапазона: новый диапазон забирает флаг себе
   function addRange() {
-    setCooldownRanges([...cooldownRanges, { min: "", max: "", period: "" }]);
+    const last = cooldownRanges[cooldownRanges.length - 1];
+    setCooldownRanges([
+      ...cooldownRanges.map((r) => ({ ...r, unbounded: false })),
+      { min: "", max: "", period: "", unbounded: last ? last.unbounded : true },
+    ]);
   }
 
   function removeRange(idx: number) {
     const arr = cooldownRanges.filter((_, i) => i !== idx);
-    setCooldownRanges(arr.length ? arr : [{ min: "", max: "", period: "" }]);
+    if (arr.length && cooldownRanges[idx].unbounded) {
+      arr[arr.length - 1] = { ...arr[arr.length - 1], unbounded: true, max: "" };
+    }
+    setCooldownRanges(arr.length ? arr : [{ min: "", max: "", period: "", unbounded: true }]);
   }
 
   async function handleSave() {
     const payload: SettingsPayload = {
       cooldowns: cooldownRanges
-        .filter((r) => r.min !== "" && r.max !== "" && r.period !== "")
-        .map((r) => ({ min: Number(r.min), max: Number(r.max), period: Number(r.period) })),
+        .filter((r) => r.min !== "" && (r.unbounded || r.max !== "") && r.period !== "")
+        .map((r) =>
+          r.unbounded
+            ? { min: Number(r.min), period: Number(r.period), unbounded: true }
+            : { min: Number(r.min), max: Number(r.max), period: Number(r.period) }
+        ),
       notificationFrequency,
       excludedProducts,
       notificationChannel,
       totalSpent: totalSpent === "" ? 0 : Number(totalSpent),
       monthlySaving: monthlySaving === "" ? 0 : Number(monthlySaving),
     };
+    // JSON Merge Patch только с измененными полями: токены, пароли и почта,
+    // которых нет на этой странице, на сервере не трогаются
+    const patch: Partial<SettingsPayload> = {};
+    for (const key of Object.keys(payload) as (keyof SettingsPayload)[]) {
+      if (JSON.stringify(payload[key]) !== JSON.stringify(saved.current[key])) {
+        (patch as any)[key] = payload[key];
+      }
+    }
     try {
-      const res = await fetch(`${SETTINGS_API}/${userId}`, {
-        method: "POST",
-        headers: { "Content-Type": "application/json" },
-        body: JSON.stringify(payload),
-      });
-      if (!res.ok) throw new Error(await res.text());
+      if (Object.keys(patch).length > 0) {
+        const res = await apiFetch(userId, `${SETTINGS_API}/${userId}`, {
+          method: "PATCH",
+          headers: { "Content-Type": "application/merge-patch+json" },
+          body: JSON.stringify(patch),
+        });
+        if (!res.ok) throw new Error(await res.text());
+        saved.current = { ...saved.current, ...patch };
+      }
       alert("Настройки сохранены");
       router.push("/");
     } catch (err) {
@@ -107,7 +154,7 @@ export default function SettingsPage() {
   }
 
   async function sendTestNotification() {
-    await fetch(`http://localhost:8080/api/notify/${userId}`, {
+    await apiFetch(userId, `http://localhost:8080/api/v1/notify/${userId}`, {
       method: "POST",
       headers: { "Content-Type": "application/json" },
       body: JSON.stringify({
@@ -176,10 +223,11 @@ export default function SettingsPage() {
               />
               <input
                 type="number"
-                placeholder="До (рублей)"
+                placeholder={r.unbounded ? "Без потолка" : "До (рублей)"}
                 value={r.max}
+                disabled={r.unbounded}
                 onChange={(e) => handleRangeChange(i, "max", e.target.value)}
-                className="w-full md:w-1/3 p-2 rounded bg-gray-900 text-yellow-100 border border-yellow-600 focus:outline-none focus:ring-2 focus:ring-yellow-400 transition"
+                className="w-full md:w-1/3 p-2 rounded bg-gray-900 text-yellow-100 border border-yellow-600 focus:outline-none focus:ring-2 focus:ring-yellow-400 transition disabled:opacity-50"
               />
               <input
                 type="number"
@@ -188,6 +236,17 @@ export default function SettingsPage() {
                 onChange={(e) => handleRangeChange(i, "period", e.target.value)}
                 className="w-full md:w-1/3 p-2 rounded bg-gray-900 text-yellow-100 border border-yellow-600 focus:outline-none focus:ring-2 focus:ring-yellow-400 transition"
               />
+              {i === cooldownRanges.length - 1 && (
+                <label className="flex items-center gap-2 whitespace-nowrap text-sm">
+                  <input
+                    type="checkbox"
+                    checked={r.unbounded}
+                    onChange={(e) => toggleUnbounded(i, e.target.checked)}
+                    className="accent-yellow-400"
+                  />
+                  <span>потолка нет</span>
+                </label>
+              )}
               <button
                 onClick={() => removeRange(i)}
                 className="px-3 py-1 bg-red-600 hover:bg-red-700 text-white rounded transition"